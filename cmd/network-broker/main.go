@@ -4,17 +4,23 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
 
+	log "github.com/sirupsen/logrus"
+	"github.com/syndtr/gocapability/capability"
+
 	"github.com/vmware/network-event-broker/listeners"
+	"github.com/vmware/network-event-broker/pkg/bus"
 	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/dns"
 	"github.com/vmware/network-event-broker/pkg/network"
 	"github.com/vmware/network-event-broker/pkg/system"
-	log "github.com/sirupsen/logrus"
 )
 
 func run(c *conf.Config) {
@@ -30,8 +36,62 @@ func run(c *conf.Config) {
 		os.Exit(1)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// One connection to the system bus is shared by all clients and kept up
+	go n.Bus.Conn.Watch(ctx)
+
+	n.RoutingPolicyMode = network.ParseRoutingPolicyMode(c.Network.RoutingPolicyMode)
+
+	// Each entry of DNSPriority= ranks the links it matches
+	var priority []dns.LinkMatcher
+	for _, p := range strings.Fields(c.Network.DNSPriority) {
+		priority = append(priority, network.NewLinkMatcher(p))
+	}
+	n.DNS = dns.New(c, n.Bus, priority)
+
+	n.HostnamePolicy = network.NewHostnamePolicy(c)
+
+	if c.Network.MultiPathDefaultRoute {
+		n.MultiPath = network.NewMultiPath(c)
+	}
+
+	if len(c.Sysctl.Links) > 0 {
+		n.Sysctl = network.NewSysctl(c)
+		n.ApplyAllSysctls()
+	}
+
+	if c.Network.Masquerade != "" {
+		n.Masquerade = network.NewMasquerade(c)
+		n.UpdateAllMasquerades()
+	}
+
+	if len(c.TrafficControl.Links) > 0 {
+		n.TrafficControl = network.NewTrafficControl(c)
+		go network.WatchTrafficControl(n, c)
+	}
+
+	network.WatchActions(n, c)
+
 	// Watch network
-	go network.WatchNetwork(n)
+	go network.WatchNetwork(n, c)
+
+	if c.Control.Socket != "" {
+		go network.WatchControl(n, c)
+	}
+
+	if c.GatewayMonitor.Links != "" {
+		go network.WatchGateways(n, c)
+	}
+
+	if c.Namespace.Names != "" {
+		go network.WatchNamespaces(c)
+	}
+
+	if c.Statistics.Links != "" {
+		go network.WatchStatistics(c)
+	}
 
 	finished := make(chan bool)
 
@@ -48,6 +108,8 @@ func run(c *conf.Config) {
 	signal.Notify(s, syscall.SIGTERM)
 	go func() {
 		<-s
+		n.RestoreAllSysctls()
+		n.Bus.Conn.Close()
 		os.Exit(0)
 	}()
 
@@ -57,11 +119,17 @@ func run(c *conf.Config) {
 func main() {
 	c, err := conf.Parse()
 	if err != nil {
-		log.Warnf("Failed to parse configuration: %v", err)
+		log.Errorf("Failed to parse configuration: %v", err)
+		os.Exit(1)
 	}
 
 	log.Infof("network-broker: v%s (built %s)", conf.Version, runtime.Version())
 
+	// Which capabilities are kept depends on the DNS backend, so it is detected while still root
+	conn := bus.NewConn()
+	c.Network.DNSBackend = dns.Detect(c, bus.NewResolved(conn))
+	conn.Close()
+
 	cred, err := system.GetUserCredentials("")
 	if err != nil {
 		log.Warningf("Failed to get current user credentials: %+v", err)
@@ -73,6 +141,13 @@ func main() {
 				log.Errorf("Failed to get user 'network-broker' credentials: %+v", err)
 				os.Exit(1)
 			} else {
+				// The control socket is created after switching user
+				if c.Control.Socket != "" {
+					if err := system.CreateUserDir(filepath.Dir(c.Control.Socket), u); err != nil {
+						log.Warningf("Failed to create control socket dir: %+v", err)
+					}
+				}
+
 				if err := system.EnableKeepCapability(); err != nil {
 					log.Warningf("Failed to enable keep capabilities: %+v", err)
 				}
@@ -85,7 +160,18 @@ func main() {
 					log.Warningf("Failed to disable keep capabilities: %+v", err)
 				}
 
-				err := system.ApplyCapability(u)
+				// The file based DNS backends write /etc/resolv.conf owned by root
+				var extra []capability.Cap
+				if dns.NeedsFileAccess(c.Network.DNSBackend) {
+					extra = append(extra, capability.CAP_DAC_OVERRIDE)
+				}
+
+				// and the file written in place of it is handed back to its owner
+				if c.Network.DNSBackend == dns.BackendResolvConfFile {
+					extra = append(extra, capability.CAP_CHOWN)
+				}
+
+				err := system.ApplyCapability(u, extra...)
 				if err != nil {
 					log.Warningf("Failed to apply capabilities: +%v", err)
 				}
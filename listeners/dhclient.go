@@ -5,56 +5,22 @@ package listeners
 
 import (
 	"encoding/json"
-	"net"
 	"os"
 	"os/exec"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/sys/unix"
 
-	"github.com/vmware/network-event-broker/pkg/bus"
 	"github.com/vmware/network-event-broker/pkg/conf"
 	"github.com/vmware/network-event-broker/pkg/network"
 	"github.com/vmware/network-event-broker/pkg/parser"
 	"github.com/vmware/network-event-broker/pkg/system"
 )
 
-func setDnsServer(dnsServers []net.IP, index int) error {
-	linkDns := make([]bus.DnsServer, len(dnsServers))
-	for i, s := range dnsServers {
-		linkDns[i] = bus.DnsServer{
-			Family:  unix.AF_INET,
-			Address: []byte(s.To4()),
-		}
-	}
-
-	if err := bus.SetResolveDNS(linkDns, index); err != nil {
-		log.Warnln(err)
-	}
-
-	return nil
-}
-
-func setDnsDomain(dnsDomains []string, index int) error {
-	linkDomains := make([]bus.Domain, len(dnsDomains))
-	for i, domain := range dnsDomains {
-		linkDomains[i] = bus.Domain{
-			Domain: domain,
-			Set:    true,
-		}
-	}
-
-	if err := bus.SetResolveDomain(linkDomains, index); err != nil {
-		log.Warnln(err)
-	}
-
-	return nil
-}
-
 func executeDHClientLinkStateScripts(n *network.Network, link string, strIndex string, dns string, domain string, domainSearch string, lease string, c *conf.Config) error {
 	scripts, err := system.ReadAllScriptInConfDir(path.Join(conf.ConfPath, "routable.d"))
 	if err != nil {
@@ -64,7 +30,7 @@ func executeDHClientLinkStateScripts(n *network.Network, link string, strIndex s
 
 	var jsonData string
 	if c.Network.EmitJSON {
-		m, err := acquireLink(link)
+		m, err := acquireLink(n, link)
 		if err == nil {
 			m.DNS = []string{dns}
 			m.Domains = []string{domain}
@@ -77,11 +43,25 @@ func executeDHClientLinkStateScripts(n *network.Network, link string, strIndex s
 		}
 	}
 
+	var vrf string
+	if v, err := network.LinkVRF(link); err == nil {
+		vrf = "VRF=" + v
+	}
+
+	var qdisc string
+	if idx, ok := n.LinksByName[link]; ok {
+		if q, err := network.LinkQdisc(idx); err == nil {
+			qdisc = "QDISC=" + q
+		}
+	}
+
 	link = "LINK=" + link
 	strIndex = "LINKINDEX=" + strIndex
 	dns = "DNS=" + dns
 	domain = "DOMAIN=" + domain
 
+	system.RunEventHooks("routable.d", link, strIndex, lease, dns, domain)
+
 	for _, s := range scripts {
 		script := path.Join(conf.ConfPath, "routable.d", s)
 
@@ -101,8 +81,16 @@ func executeDHClientLinkStateScripts(n *network.Network, link string, strIndex s
 			cmd.Env = append(cmd.Env, jsonData)
 		}
 
+		if vrf != "" {
+			cmd.Env = append(cmd.Env, vrf)
+		}
+
+		if qdisc != "" {
+			cmd.Env = append(cmd.Env, qdisc)
+		}
+
 		if err := cmd.Run(); err != nil {
-			log.Errorf("Failed to execute script='%s': %w", script, err)
+			log.Errorf("Failed to execute script='%s': %v", script, err)
 			continue
 		}
 
@@ -112,12 +100,16 @@ func executeDHClientLinkStateScripts(n *network.Network, link string, strIndex s
 	return nil
 }
 
+const dhclientLeaseExpiryInterval = time.Minute
+
 func TaskDHClient(n *network.Network, c *conf.Config) error {
 	leases, err := parser.ParseDHClientLease()
 	if err != nil {
-		log.Debugf("Failed to parse DHClient lease file '%s': %w", conf.DHClientLeaseFile, err)
+		log.Debugf("Failed to parse DHClient lease file '%s': %v", conf.DHClientLeaseFile, err)
 	}
 
+	links := network.NewLinkMatcher(c.Network.Links)
+
 	for i, lease := range leases {
 		_, ok := n.LinksByName[i]
 		if !ok {
@@ -125,10 +117,14 @@ func TaskDHClient(n *network.Network, c *conf.Config) error {
 		}
 
 		idx := n.LinksByName[i]
-		if c.Network.Links != "" {
-			if !strings.Contains(c.Network.Links, n.LinksByIndex[idx]) {
-				continue
-			}
+		if !links.IsEmpty() && !links.Match(n.LinksByIndex[idx]) {
+			continue
+		}
+
+		if lease.IsExpired() {
+			n.RevertLink(idx, "lease expired", false)
+			n.SwapLinkRoutable(idx, false)
+			continue
 		}
 
 		strIndex := strconv.Itoa(idx)
@@ -141,34 +137,60 @@ func TaskDHClient(n *network.Network, c *conf.Config) error {
 
 		executeDHClientLinkStateScripts(n, i, strIndex, dns, domain, domainSearch, dhcpLease, c)
 
-		if c.Network.UseHostname {
-			if err := bus.SetHostname(lease.Hostname); err != nil {
-				log.Warnln("Failed to set hostname='%s': %+w", lease.Hostname, err)
+		n.SwapLinkRoutable(idx, true)
+		n.ApplySysctls(idx, i)
+		n.ApplyQdisc(idx, i)
+
+		if c.Network.UseHostname && lease.Hostname != "" {
+			if err := n.SetHostnameFromLink(idx, lease.Hostname); err != nil {
+				log.Warnf("Failed to set hostname='%s': %v", lease.Hostname, err)
 			}
 		}
 
-		if c.Network.UseDNS && len(lease.Dns) > 0 {
-			var dnsServers []net.IP
+		var dnsServers, dnsDomains []string
+		if c.Network.UseDNS {
+			dnsServers = lease.Dns
+		}
 
-			for _, d := range lease.Dns {
-				v, _ := parser.ParseIP(strings.TrimSpace(d))
-				dnsServers = append(dnsServers, v)
-			}
-			setDnsServer(dnsServers, idx)
+		if c.Network.UseDomain {
+			dnsDomains = leaseDomains(lease, i, c)
 		}
 
-		if c.Network.UseDomain && len(lease.Domain) > 0 {
-			setDnsDomain(lease.Domain, idx)
+		configureLinkResolve(n, idx, i, dnsServers, dnsDomains, c)
+
+		if c.Network.UseNTP && len(lease.Ntp) > 0 {
+			if err := n.SetLinkNTP(idx, lease.Ntp); err != nil {
+				log.Warnf("Failed to set NTP servers of link='%s' ifindex='%d': %v", i, idx, err)
+			}
 		}
 	}
 
 	return nil
 }
 
+// expireDHClientLeases reverts the settings pushed for leases which expired since the lease
+// file was last written, and drops their links from the multipath default route.
+func expireDHClientLeases(n *network.Network) {
+	leases, err := parser.ParseDHClientLease()
+	if err != nil {
+		return
+	}
+
+	for i, lease := range leases {
+		idx, ok := n.LinksByName[i]
+		if !ok || !lease.IsExpired() {
+			continue
+		}
+
+		n.RevertLink(idx, "lease expired", false)
+		n.SwapLinkRoutable(idx, false)
+	}
+}
+
 func WatchDHClient(n *network.Network, c *conf.Config, finished chan bool) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Errorf("Failed to watch DHClient lease: %w", err)
+		log.Errorf("Failed to watch DHClient lease: %v", err)
 	}
 	defer watcher.Close()
 
@@ -179,9 +201,15 @@ func WatchDHClient(n *network.Network, c *conf.Config, finished chan bool) {
 
 	done := make(chan bool)
 
+	ticker := time.NewTicker(dhclientLeaseExpiryInterval)
+	defer ticker.Stop()
+
 	go func() {
 		for {
 			select {
+			case <-ticker.C:
+				expireDHClientLeases(n)
+
 			case event := <-watcher.Events:
 				log.Debugf("DHClient Received event: %s", event.Op.String())
 
@@ -194,7 +222,7 @@ func WatchDHClient(n *network.Network, c *conf.Config, finished chan bool) {
 	}()
 
 	if err := watcher.Add(conf.DHClientLeaseFile); err != nil {
-		log.Errorf("Failed to watch DHClient lease file: %w", err)
+		log.Errorf("Failed to watch DHClient lease file: %v", err)
 	}
 
 	<-done
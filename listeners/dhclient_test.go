@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package listeners
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const testLease = `lease {
+  interface "%s";
+  fixed-address 192.0.2.10;
+  option subnet-mask 255.255.255.0;
+  option routers 192.0.2.1;
+  option domain-name-servers 192.0.2.53,192.0.2.54;
+  option ntp-servers 192.0.2.123;
+  option host-name "node1.example.com";
+  option domain-name "example.com";
+  expire 0 %s;
+}
+`
+
+func writeLease(t *testing.T, expire time.Time) {
+	t.Helper()
+
+	lease := fmt.Sprintf(testLease, testLink, expire.UTC().Format("2006/01/02 15:04:05"))
+	if err := os.WriteFile(conf.DHClientLeaseFile, []byte(lease), 0644); err != nil {
+		t.Fatalf("Failed to write lease file: %v", err)
+	}
+}
+
+func TestTaskDHClient(t *testing.T) {
+	n, f := newTestNetwork(t)
+
+	leaseFile := conf.DHClientLeaseFile
+	conf.DHClientLeaseFile = filepath.Join(t.TempDir(), "dhclient.leases")
+	t.Cleanup(func() { conf.DHClientLeaseFile = leaseFile })
+
+	c := &conf.Config{
+		Network: conf.Network{
+			UseDNS:      true,
+			UseDomain:   true,
+			UseHostname: true,
+			UseNTP:      true,
+		},
+	}
+
+	writeLease(t, time.Now().Add(time.Hour))
+	TaskDHClient(n, c)
+
+	var servers []string
+	for _, d := range f.resolved.WaitCall(t, "SetLinkDNSEx").Args[1].([]bus.DnsServerEx) {
+		servers = append(servers, net.IP(d.Address).String())
+	}
+	if want := []string{"192.0.2.53", "192.0.2.54"}; !reflect.DeepEqual(servers, want) {
+		t.Errorf("SetLinkDNSEx servers='%v', want '%v'", servers, want)
+	}
+
+	domains := f.resolved.WaitCall(t, "SetLinkDomains").Args[1].([]bus.Domain)
+	if want := []bus.Domain{{Domain: "example.com"}}; !reflect.DeepEqual(domains, want) {
+		t.Errorf("SetLinkDomains domains='%+v', want '%+v'", domains, want)
+	}
+
+	call := f.networkd.WaitCall(t, "SetLinkNTP")
+	if want := []interface{}{int32(testIndex), []string{"192.0.2.123"}}; !reflect.DeepEqual(call.Args, want) {
+		t.Errorf("SetLinkNTP args='%v', want '%v'", call.Args, want)
+	}
+
+	if h := f.hostnamed.Property("Hostname"); h != "node1" {
+		t.Errorf("Hostname='%s', want 'node1'", h)
+	}
+
+	if !n.IsLinkRoutable(testIndex) {
+		t.Errorf("Link not recorded as routable")
+	}
+
+	// An expired lease reverts all settings pushed for it
+	writeLease(t, time.Now().Add(-time.Hour))
+	TaskDHClient(n, c)
+
+	if call := f.resolved.WaitCall(t, "RevertLink"); call.Args[0] != int32(testIndex) {
+		t.Errorf("RevertLink ifindex='%v', want '%d'", call.Args[0], testIndex)
+	}
+
+	if call := f.networkd.WaitCall(t, "RevertLinkNTP"); call.Args[0] != int32(testIndex) {
+		t.Errorf("RevertLinkNTP ifindex='%v', want '%d'", call.Args[0], testIndex)
+	}
+
+	if h := f.hostnamed.Property("Hostname"); h != "localhost" {
+		t.Errorf("Hostname='%s' not restored to 'localhost'", h)
+	}
+
+	if n.IsLinkRoutable(testIndex) {
+		t.Errorf("Link of the expired lease still recorded as routable")
+	}
+}
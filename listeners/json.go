@@ -9,10 +9,10 @@ import (
 	"path"
 
 	"github.com/jaypipes/ghw"
-	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 
 	"github.com/vmware/network-event-broker/pkg/configfile"
+	"github.com/vmware/network-event-broker/pkg/network"
 	"github.com/vmware/network-event-broker/pkg/parser"
 )
 
@@ -84,7 +84,8 @@ type LinkDescribe struct {
 	GSOMaxSegs      uint32 `json:"GSOMaxSegs"`
 	Group           uint32 `json:"Group"`
 	Slave           string `json:"Slave"`
-	KernelOperState string `json: "KernelOperState"`
+	KernelOperState string `json:"KernelOperState"`
+	Qdisc           string `json:"Qdisc"`
 
 	AddressState     string `json:"AddressState"`
 	CarrierState     string `json:"CarrierState"`
@@ -199,6 +200,8 @@ func fillOneLink(link netlink.Link) *LinkDescribe {
 		Flags:           link.Attrs().Flags.String(),
 	}
 
+	l.Qdisc, _ = network.LinkQdisc(link.Attrs().Index)
+
 	l.AddressState, _ = ParseLinkAddressState(link.Attrs().Index)
 	l.IPv4AddressState, _ = ParseLinkIPv4AddressState(link.Attrs().Index)
 	l.IPv6AddressState, _ = ParseLinkIPv6AddressState(link.Attrs().Index)
@@ -264,24 +267,18 @@ func buildLinkMessageFallback(link string) (*LinkDescribe, error) {
 	return fillOneLink(l), nil
 }
 
-func acquireLink(link string) (*LinkDescribe, error) {
-	c, err := NewSDConnection()
-	if err != nil {
-		log.Errorf("Failed to establish connection to the system bus: %s", err)
-		return nil, err
-	}
-	defer c.Close()
-
+func acquireLink(n *network.Network, link string) (*LinkDescribe, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultRequestTimeout)
 	defer cancel()
 
-	links, err := c.DBusLinkDescribe(ctx)
+	links, err := DBusLinkDescribe(ctx, n.Bus.Networkd)
 	if err != nil {
 		return buildLinkMessageFallback(link)
 	}
 
 	for _, l := range links.Interfaces {
 		if l.Name == link {
+			l.Qdisc, _ = network.LinkQdisc(l.Index)
 			return &l, nil
 		}
 	}
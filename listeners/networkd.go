@@ -23,16 +23,15 @@ import (
 )
 
 const (
-	networkInterface  = "org.freedesktop.network1"
-	networkObjectPath = "/org/freedesktop/network1"
-
-	networkInterfaceLink       = "org.freedesktop.network1.Link"
-	networkInterfaceLinkEscape = networkObjectPath + "/link/_3"
+	networkInterfaceLink       = bus.NetworkInterface + ".Link"
+	networkInterfaceLinkEscape = bus.NetworkObjectPath + "/link/_3"
 
 	defaultRequestTimeout = 5 * time.Second
 )
 
-func executeNetworkdLinkStateScripts(link string, index int, k string, v string, c *conf.Config) error {
+func executeNetworkdLinkStateScripts(n *network.Network, link string, index int, k string, v string, c *conf.Config) error {
+	system.RunEventHooks(v+".d", "LINK="+link, "LINKINDEX="+strconv.Itoa(index), k+"="+v)
+
 	scriptDirs, err := system.ReadAllScriptDirs(conf.ConfPath)
 	if err != nil {
 		log.Errorf("Failed to find any scripts in conf dir: %+v", err)
@@ -62,7 +61,7 @@ func executeNetworkdLinkStateScripts(link string, index int, k string, v string,
 			leaseFile := path.Join(conf.NetworkdLeasePath, strconv.Itoa(index))
 			leaseLines, err := system.ReadLines(leaseFile)
 			if err != nil {
-				log.Debugf("Failed to read lease file of link='%+v'", link, err)
+				log.Debugf("Failed to read lease file of link='%+v': %v", link, err)
 				continue
 			}
 
@@ -74,7 +73,7 @@ func executeNetworkdLinkStateScripts(link string, index int, k string, v string,
 
 			var jsonData string
 			if c.Network.EmitJSON {
-				m, err := acquireLink(link)
+				m, err := acquireLink(n, link)
 				if err == nil {
 					j, _ := json.Marshal(m)
 					jsonData = "JSON=" + string(j)
@@ -101,6 +100,14 @@ func executeNetworkdLinkStateScripts(link string, index int, k string, v string,
 					cmd.Env = append(cmd.Env, jsonData)
 				}
 
+				if vrf, err := network.LinkVRF(link); err == nil {
+					cmd.Env = append(cmd.Env, "VRF="+vrf)
+				}
+
+				if qdisc, err := network.LinkQdisc(index); err == nil {
+					cmd.Env = append(cmd.Env, "QDISC="+qdisc)
+				}
+
 				if err := cmd.Run(); err != nil {
 					log.Errorf("Failed to execute script='%s': %v", script, err)
 					continue
@@ -115,6 +122,8 @@ func executeNetworkdLinkStateScripts(link string, index int, k string, v string,
 }
 
 func executeNetworkdManagerScripts(k string, v string) error {
+	system.RunEventHooks(conf.ManagerStateDir, k+"="+v)
+
 	managerStatePath := path.Join(conf.ConfPath, conf.ManagerStateDir)
 
 	scripts, err := system.ReadAllScriptInConfDir(managerStatePath)
@@ -146,6 +155,47 @@ func executeNetworkdManagerScripts(k string, v string) error {
 	return nil
 }
 
+// configureNetworkdLinkResolve pushes the [Resolve] settings of the link in addition to the
+// DNS servers and domains systemd-networkd configured for it.
+func configureNetworkdLinkResolve(n *network.Network, index int, link string, c *conf.Config) {
+	if matchResolveProfile(link, c) == nil {
+		return
+	}
+
+	dns, _ := ParseLinkDNS(index)
+	domains, _ := ParseLinkDomains(index)
+
+	routeDomains, _ := ParseLinkRouteDomains(index)
+	for _, d := range routeDomains {
+		if d != "" {
+			domains = append(domains, "~"+d)
+		}
+	}
+
+	configureLinkResolve(n, index, link, dns, domains, c)
+}
+
+// configureNetworkdLinkNTP pushes the NTP servers systemd-networkd received for the link.
+func configureNetworkdLinkNTP(n *network.Network, index int, link string, c *conf.Config) {
+	if !c.Network.UseNTP {
+		return
+	}
+
+	ntp, err := ParseLinkNTP(index)
+	if err != nil {
+		return
+	}
+
+	ntp = appendUnique(nil, ntp...)
+	if len(ntp) == 0 {
+		return
+	}
+
+	if err := n.SetLinkNTP(index, ntp); err != nil {
+		log.Warnf("Failed to set NTP servers of link='%s' ifindex='%d': %v", link, index, err)
+	}
+}
+
 func processDBusLinkMessage(n *network.Network, v *dbus.Signal, c *conf.Config) error {
 	if !strings.HasPrefix(string(v.Path), networkInterfaceLinkEscape) {
 		return nil
@@ -160,23 +210,39 @@ func processDBusLinkMessage(n *network.Network, v *dbus.Signal, c *conf.Config)
 
 	log.Debugf("Received DBus signal from systemd-networkd for ifindex='%d'", index)
 
+	links := network.NewLinkMatcher(c.Network.Links)
+	routingPolicyRules := network.NewLinkMatcher(c.Network.RoutingPolicyRules)
+
 	linkState := v.Body[1].(map[string]dbus.Variant)
 	for k, v := range linkState {
 		s := strings.Trim(v.String(), "\"")
 
 		log.Debugf("Link='%s' ifindex='%d' changed state '%s'='%s'", n.LinksByIndex[index], index, k, s)
 
-		if c.Network.Links != "" {
-			if strings.Contains(c.Network.Links, n.LinksByIndex[index]) {
-				executeNetworkdLinkStateScripts(n.LinksByIndex[index], index, k, s, c)
-			}
-		} else {
-			executeNetworkdLinkStateScripts(n.LinksByIndex[index], index, k, s, c)
+		if links.IsEmpty() || links.Match(n.LinksByIndex[index]) {
+			executeNetworkdLinkStateScripts(n, n.LinksByIndex[index], index, k, s, c)
 		}
 
-		if s == "routable" && strings.Contains(c.Network.RoutingPolicyRules, n.LinksByIndex[index]) {
+		if s == "routable" && routingPolicyRules.Match(n.LinksByIndex[index]) {
 			network.ConfigureNetwork(n.LinksByIndex[index], n)
 		}
+
+		if k == "OperationalState" {
+			wasRoutable := n.SwapLinkRoutable(index, s == "routable")
+
+			if s == "routable" && !wasRoutable {
+				configureNetworkdLinkResolve(n, index, n.LinksByIndex[index], c)
+				configureNetworkdLinkNTP(n, index, n.LinksByIndex[index], c)
+			} else if s != "routable" && wasRoutable {
+				n.RevertLink(index, "no longer routable", false)
+			}
+		}
+
+		// systemd-networkd writes some of the sysctls itself while configuring the link
+		if k == "AdministrativeState" && s == "configured" {
+			n.ApplySysctls(index, n.LinksByIndex[index])
+			n.ApplyQdisc(index, n.LinksByIndex[index])
+		}
 	}
 
 	return nil
@@ -197,28 +263,39 @@ func processDBusManagerMessage(n *network.Network, v *dbus.Signal) error {
 }
 
 func WatchNetworkd(n *network.Network, c *conf.Config, finished chan bool) error {
-	conn, err := dbus.ConnectSystemBus()
-	if err != nil {
-		log.Fatalf("Failed to connect to system bus: %v", err)
-		os.Exit(1)
-	}
-	defer conn.Close()
+	sigChannel := make(chan *dbus.Signal, 512)
 
-	opts := []dbus.MatchOption{
-		dbus.WithMatchSender(networkInterface),
+	err := n.Bus.Conn.Subscribe(sigChannel,
+		dbus.WithMatchSender(bus.NetworkInterface),
 		dbus.WithMatchInterface(bus.DBusProperties),
 		dbus.WithMatchMember("PropertiesChanged"),
-	}
-
-	if err := conn.AddMatchSignal(opts...); err != nil {
-		log.Errorf("Failed to add match signal for '%s`: %+v", networkInterface, err)
+	)
+	if err != nil {
+		log.Errorf("Failed to add match signal for '%s`: %+v", bus.NetworkInterface, err)
 		return err
 	}
 
 	log.Infoln("Listening to 'systemd-networkd' DBus events")
 
-	sigChannel := make(chan *dbus.Signal, 512)
-	conn.Signal(sigChannel)
+	// Links configured before we started do not send a signal
+	n.Mutex.Lock()
+	links := make(map[int]string, len(n.LinksByIndex))
+	for index, link := range n.LinksByIndex {
+		links[index] = link
+	}
+	n.Mutex.Unlock()
+
+	for index, link := range links {
+		if s, err := ParseLinkSetupState(index); err == nil && s == "configured" {
+			n.ApplyQdisc(index, link)
+		}
+
+		if s, err := ParseLinkOperationalState(index); err == nil && s == "routable" {
+			n.SwapLinkRoutable(index, true)
+			configureNetworkdLinkResolve(n, index, link, c)
+			configureNetworkdLinkNTP(n, index, link, c)
+		}
+	}
 
 	for v := range sigChannel {
 		w := fmt.Sprintf("%v", v.Body[0])
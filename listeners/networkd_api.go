@@ -83,6 +83,15 @@ func ParseLinkDomains(ifindex int) ([]string, error) {
 	return strings.Split(s, " "), nil
 }
 
+func ParseLinkRouteDomains(ifindex int) ([]string, error) {
+	s, err := ParseLinkString(ifindex, "ROUTE_DOMAINS")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(s, " "), nil
+}
+
 func ParseNetworkState(key string) (string, error) {
 	v, err := configfile.ParseKeyFromSectionString("/run/systemd/netif/state", "", key)
 	if err != nil {
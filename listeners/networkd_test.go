@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package listeners
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/bus/bustest"
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/dns"
+	"github.com/vmware/network-event-broker/pkg/network"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+// The link is made up so that nothing is read from a real systemd-networkd
+const (
+	testLink  = "nbtest0"
+	testIndex = 4242
+)
+
+type fakes struct {
+	networkd  *bustest.Networkd
+	resolved  *bustest.Resolved
+	hostnamed *bustest.Hostnamed
+}
+
+func newTestNetwork(t *testing.T) (*network.Network, *fakes) {
+	b := bustest.NewBus(t)
+
+	f := &fakes{
+		networkd:  bustest.NewNetworkd(t, b),
+		resolved:  bustest.NewResolved(t, b),
+		hostnamed: bustest.NewHostnamed(t, b, "localhost"),
+	}
+
+	n := network.New()
+	n.Bus = b.Clients(t)
+	n.DNS = dns.NewResolved(n.Bus.Resolved)
+	n.LinksByName[testLink] = testIndex
+	n.LinksByIndex[testIndex] = testLink
+
+	return n, f
+}
+
+type event struct {
+	dir string
+	env []string
+}
+
+// watchEvents reports the events dispatched until the test ends. Events nobody reads are
+// dropped.
+func watchEvents(t *testing.T) <-chan event {
+	events := make(chan event, 64)
+
+	remove := system.AddEventHook(func(dir string, env []string) {
+		select {
+		case events <- event{dir: dir, env: env}:
+		default:
+		}
+	})
+	t.Cleanup(remove)
+
+	return events
+}
+
+// watchNetworkd runs WatchNetworkd until the test ends, when closing the connection to the bus
+// ends it.
+func watchNetworkd(t *testing.T, n *network.Network, c *conf.Config) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchNetworkd(n, c, make(chan bool, 1))
+	}()
+
+	t.Cleanup(func() {
+		n.Bus.Conn.Close()
+
+		select {
+		case <-done:
+		case <-time.After(bustest.WaitTimeout):
+			t.Errorf("WatchNetworkd did not stop once the bus connection was closed")
+		}
+	})
+}
+
+// emitUntil emits until done reports true, as the watcher subscribes in the background.
+func emitUntil(t *testing.T, emit func() error, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(bustest.WaitTimeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the signal to be handled")
+		}
+
+		if err := emit(); err != nil {
+			t.Fatalf("Failed to emit signal: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func emitOperationalState(f *fakes, state string) func() error {
+	return func() error {
+		return f.networkd.EmitLinkProperties(testIndex, map[string]dbus.Variant{
+			"OperationalState": dbus.MakeVariant(state),
+		})
+	}
+}
+
+func matchEvent(e event, dir string, env string) bool {
+	for _, v := range e.env {
+		if e.dir == dir && v == env {
+			return true
+		}
+	}
+
+	return false
+}
+
+func waitEvent(t *testing.T, events <-chan event, dir string, env string) {
+	t.Helper()
+
+	timeout := time.After(bustest.WaitTimeout)
+	for {
+		select {
+		case e := <-events:
+			if matchEvent(e, dir, env) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for event='%s' env='%s'", dir, env)
+		}
+	}
+}
+
+func TestWatchNetworkdLinkState(t *testing.T) {
+	n, f := newTestNetwork(t)
+	events := watchEvents(t)
+
+	c := &conf.Config{
+		Resolve: conf.Resolve{
+			Links: map[string]string{testLink: "DNS=192.0.2.53 Domains=example.com,~corp.example.com"},
+		},
+	}
+
+	watchNetworkd(t, n, c)
+
+	emitUntil(t, emitOperationalState(f, "routable"), func() bool {
+		return len(f.resolved.Calls("SetLinkDomains")) > 0
+	})
+
+	waitEvent(t, events, "routable.d", "LINK="+testLink)
+
+	call := f.resolved.WaitCall(t, "SetLinkDNSEx")
+	if call.Args[0] != int32(testIndex) {
+		t.Errorf("SetLinkDNSEx ifindex='%v', want '%d'", call.Args[0], testIndex)
+	}
+
+	servers := call.Args[1].([]bus.DnsServerEx)
+	if len(servers) != 1 || net.IP(servers[0].Address).String() != "192.0.2.53" {
+		t.Errorf("SetLinkDNSEx servers='%+v', want '192.0.2.53'", servers)
+	}
+
+	domains := f.resolved.WaitCall(t, "SetLinkDomains").Args[1].([]bus.Domain)
+	want := []bus.Domain{{Domain: "example.com"}, {Domain: "corp.example.com", RoutingOnly: true}}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("SetLinkDomains domains='%+v', want '%+v'", domains, want)
+	}
+
+	if !n.IsLinkRoutable(testIndex) {
+		t.Errorf("Link not recorded as routable")
+	}
+
+	if err := emitOperationalState(f, "degraded")(); err != nil {
+		t.Fatalf("Failed to emit signal: %v", err)
+	}
+
+	waitEvent(t, events, "degraded.d", "OperationalState=degraded")
+
+	if call := f.resolved.WaitCall(t, "RevertLink"); call.Args[0] != int32(testIndex) {
+		t.Errorf("RevertLink ifindex='%v', want '%d'", call.Args[0], testIndex)
+	}
+}
+
+func TestWatchNetworkdDNSFallback(t *testing.T) {
+	n, f := newTestNetwork(t)
+
+	// systemd-resolved before v246
+	f.resolved.Fail("SetLinkDNSEx", dbus.NewError("org.freedesktop.DBus.Error.UnknownMethod", nil))
+
+	c := &conf.Config{
+		Resolve: conf.Resolve{
+			Links: map[string]string{testLink: "DNS=2001:db8::53"},
+		},
+	}
+
+	watchNetworkd(t, n, c)
+
+	emitUntil(t, emitOperationalState(f, "routable"), func() bool {
+		return len(f.resolved.Calls("SetLinkDNS")) > 0
+	})
+
+	servers := f.resolved.Calls("SetLinkDNS")[0].Args[1].([]bus.DnsServer)
+	if len(servers) != 1 || net.IP(servers[0].Address).String() != "2001:db8::53" {
+		t.Errorf("SetLinkDNS servers='%+v', want '2001:db8::53'", servers)
+	}
+}
+
+func TestWatchNetworkdManagerState(t *testing.T) {
+	n, f := newTestNetwork(t)
+	events := watchEvents(t)
+
+	watchNetworkd(t, n, &conf.Config{})
+
+	seen := false
+	emitUntil(t, func() error {
+		return f.networkd.EmitManagerProperties(map[string]dbus.Variant{
+			"OperationalState": dbus.MakeVariant("degraded"),
+		})
+	}, func() bool {
+		for {
+			select {
+			case e := <-events:
+				seen = seen || matchEvent(e, conf.ManagerStateDir, "OperationalState=degraded")
+			default:
+				return seen
+			}
+		}
+	})
+}
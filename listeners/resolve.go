@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package listeners
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/dns"
+	"github.com/vmware/network-event-broker/pkg/network"
+	"github.com/vmware/network-event-broker/pkg/parser"
+)
+
+// resolveProfile holds the per link settings of systemd-resolved and the hostname from the
+// [Resolve] section. Empty settings are left alone.
+type resolveProfile struct {
+	DNS          []string
+	Domains      []string
+	DomainName   string
+	DomainSearch string
+	Hostname     string
+	DNSOverTLS   string
+	DNSSEC       string
+	LLMNR        string
+	MulticastDNS string
+	DefaultRoute string
+}
+
+var resolveModes = map[string][]string{
+	"DNSOverTLS":   {"yes", "no", "opportunistic"},
+	"DNSSEC":       {"yes", "no", "allow-downgrade"},
+	"LLMNR":        {"yes", "no", "resolve"},
+	"MulticastDNS": {"yes", "no", "resolve"},
+	"DefaultRoute": {"yes", "no"},
+	"DomainName":   {"search", "route", "no"},
+	"DomainSearch": {"search", "route", "no"},
+}
+
+func validResolveMode(key string, value string) bool {
+	for _, m := range resolveModes[key] {
+		if value == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parse parses whitespace-separated 'Key=value' settings into p. Later settings
+// override earlier ones.
+func (p *resolveProfile) parse(spec string) {
+	for _, t := range strings.Fields(spec) {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			log.Warnf("Ignoring invalid resolve setting='%s'", t)
+			continue
+		}
+
+		if _, ok := resolveModes[k]; ok && !validResolveMode(k, v) {
+			log.Warnf("Ignoring resolve setting='%s': expected one of '%s'", t, strings.Join(resolveModes[k], ", "))
+			continue
+		}
+
+		switch k {
+		case "DNS":
+			p.DNS = append(p.DNS, strings.Split(v, ",")...)
+		case "Domains":
+			p.Domains = append(p.Domains, strings.Split(v, ",")...)
+		case "DomainName":
+			p.DomainName = v
+		case "DomainSearch":
+			p.DomainSearch = v
+		case "Hostname":
+			p.Hostname = v
+		case "DNSOverTLS":
+			p.DNSOverTLS = v
+		case "DNSSEC":
+			p.DNSSEC = v
+		case "LLMNR":
+			p.LLMNR = v
+		case "MulticastDNS":
+			p.MulticastDNS = v
+		case "DefaultRoute":
+			p.DefaultRoute = v
+		default:
+			log.Warnf("Ignoring unknown resolve setting='%s'", t)
+		}
+	}
+}
+
+// matchResolveProfile merges the settings of all patterns matching the link in sorted order.
+func matchResolveProfile(link string, c *conf.Config) *resolveProfile {
+	patterns := make([]string, 0, len(c.Resolve.Links))
+	for p := range c.Resolve.Links {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	var profile *resolveProfile
+	for _, p := range patterns {
+		if !network.NewLinkMatcherFold(p).Match(link) {
+			continue
+		}
+
+		if profile == nil {
+			profile = &resolveProfile{}
+		}
+		profile.parse(c.Resolve.Links[p])
+	}
+
+	return profile
+}
+
+// configureLinkResolve pushes the DNS servers and domains of the generator together with the
+// ones of the [Resolve] settings matching the link to the DNS backend, and applies the rest of
+// the settings. What was pushed is recorded so that it is reverted when the link is lost.
+func configureLinkResolve(n *network.Network, index int, link string, servers []string, domains []string, c *conf.Config) {
+	p := matchResolveProfile(link, c)
+	if p == nil {
+		p = &resolveProfile{}
+	}
+
+	servers = appendUnique(servers, p.DNS...)
+	domains = appendUnique(domains, p.Domains...)
+
+	if len(servers) > 0 {
+		if err := n.DNS.SetLinkDNS(index, link, servers); err != nil {
+			log.Warnf("Failed to set DNS servers of link='%s' ifindex='%d': %v", link, index, err)
+		}
+		n.SetResolveLink(index)
+	}
+
+	if len(domains) > 0 {
+		if err := n.DNS.SetLinkDomains(index, link, domains); err != nil {
+			log.Warnf("Failed to set DNS domains of link='%s' ifindex='%d': %v", link, index, err)
+		}
+		n.SetResolveLink(index)
+	}
+
+	if p.Hostname != "" {
+		if err := n.SetHostnameFromLink(index, p.Hostname); err != nil {
+			log.Warnf("Failed to set hostname='%s': %v", p.Hostname, err)
+		}
+	}
+
+	// The modes are specific to systemd-resolved
+	if n.DNS.Name() != dns.BackendResolved {
+		return
+	}
+
+	if applyResolveSettings(n.Bus.Resolved, index, link, p) {
+		n.SetResolveLink(index)
+	}
+}
+
+// leaseDomains returns the domain-name and domain-search entries of a lease as search domains
+// or, prefixed with '~', as routing-only domains as chosen by DomainName= and DomainSearch=.
+// The domain of a host-name given as FQDN is taken like domain-name.
+func leaseDomains(lease *parser.Lease, link string, c *conf.Config) []string {
+	p := matchResolveProfile(link, c)
+	if p == nil {
+		p = &resolveProfile{}
+	}
+
+	var domains []string
+	for _, e := range []struct {
+		mode    string
+		domains []string
+	}{
+		{p.DomainName, append(append([]string{}, lease.Domain...), hostnameDomain(lease.Hostname))},
+		{p.DomainSearch, lease.DomainSearch},
+	} {
+		if e.mode == "no" {
+			continue
+		}
+
+		// domain-name may hold several domains separated by spaces
+		for _, d := range strings.Fields(strings.Join(e.domains, " ")) {
+			d = strings.TrimSuffix(d, ".")
+			if e.mode == "route" {
+				d = "~" + d
+			}
+
+			domains = appendUnique(domains, d)
+		}
+	}
+
+	return domains
+}
+
+func hostnameDomain(hostname string) string {
+	if _, err := network.ValidateHostname(hostname); err != nil {
+		return ""
+	}
+
+	_, domain := network.SplitHostname(hostname)
+	return domain
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// applyResolveSettings pushes the per link modes of systemd-resolved and reports whether any
+// was set.
+func applyResolveSettings(r bus.Resolved, index int, link string, p *resolveProfile) bool {
+	if p.DNSOverTLS == "" && p.DNSSEC == "" && p.LLMNR == "" && p.MulticastDNS == "" && p.DefaultRoute == "" {
+		return false
+	}
+
+	log.Debugf("Applying resolve settings on link='%s' ifindex='%d': %+v", link, index, *p)
+
+	ctx := context.Background()
+
+	if p.DNSOverTLS != "" {
+		if err := r.SetLinkDNSOverTLS(ctx, index, p.DNSOverTLS); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	if p.DNSSEC != "" {
+		if err := r.SetLinkDNSSEC(ctx, index, p.DNSSEC); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	if p.LLMNR != "" {
+		if err := r.SetLinkLLMNR(ctx, index, p.LLMNR); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	if p.MulticastDNS != "" {
+		if err := r.SetLinkMulticastDNS(ctx, index, p.MulticastDNS); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	if p.DefaultRoute != "" {
+		if err := r.SetLinkDefaultRoute(ctx, index, p.DefaultRoute == "yes"); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	return true
+}
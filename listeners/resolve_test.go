@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package listeners
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/parser"
+)
+
+func TestLeaseDomains(t *testing.T) {
+	lease := &parser.Lease{
+		Domain:       []string{"example.com corp.example.com."},
+		DomainSearch: []string{"example.org", "example.com"},
+		Hostname:     "node1.lab.example.com",
+	}
+
+	tests := []struct {
+		name     string
+		resolve  map[string]string
+		lease    *parser.Lease
+		expected []string
+	}{
+		{
+			"search by default",
+			nil,
+			lease,
+			[]string{"example.com", "corp.example.com", "lab.example.com", "example.org"},
+		},
+		{
+			"routing-only domain-name",
+			map[string]string{"eth0": "DomainName=route"},
+			lease,
+			[]string{"~example.com", "~corp.example.com", "~lab.example.com", "example.org", "example.com"},
+		},
+		{
+			"no domain-search",
+			map[string]string{"eth0": "DomainSearch=no"},
+			lease,
+			[]string{"example.com", "corp.example.com", "lab.example.com"},
+		},
+		{
+			"later patterns override",
+			map[string]string{"eth*": "DomainName=no DomainSearch=no", "eth0": "DomainSearch=route"},
+			lease,
+			[]string{"~example.org", "~example.com"},
+		},
+		{
+			"other links left alone",
+			map[string]string{"eth1": "DomainName=no DomainSearch=no"},
+			&parser.Lease{Domain: []string{"example.com"}},
+			[]string{"example.com"},
+		},
+		{
+			"invalid mode ignored",
+			map[string]string{"eth0": "DomainName=maybe"},
+			&parser.Lease{Domain: []string{"example.com"}},
+			[]string{"example.com"},
+		},
+		{
+			"hostname without domain",
+			nil,
+			&parser.Lease{Hostname: "node1"},
+			nil,
+		},
+		{
+			"invalid hostname",
+			nil,
+			&parser.Lease{Hostname: "node_1.example.com"},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		c := &conf.Config{Resolve: conf.Resolve{Links: tt.resolve}}
+
+		if got := leaseDomains(tt.lease, "eth0", c); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: leaseDomains='%v', want '%v'", tt.name, got, tt.expected)
+		}
+	}
+}
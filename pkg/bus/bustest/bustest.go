@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+// Package bustest runs a private message bus with fakes of the system services network-broker
+// talks to, so that the bus clients and the listeners can be tested without systemd.
+package bustest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+)
+
+// WaitTimeout bounds waiting for calls to the fakes.
+const WaitTimeout = 5 * time.Second
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// Bus is a private dbus-daemon standing in for the system bus.
+type Bus struct {
+	Address string
+}
+
+// NewBus starts a dbus-daemon stopped when the test ends. The test is skipped when there is no
+// dbus-daemon.
+func NewBus(t testing.TB) *Bus {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(busConfig, dir)), 0644); err != nil {
+		t.Fatalf("Failed to write bus config: %v", err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to start dbus-daemon: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start dbus-daemon: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read address of dbus-daemon: %v", err)
+	}
+
+	return &Bus{Address: strings.TrimSpace(address)}
+}
+
+// Dial opens a new connection to the bus.
+func (b *Bus) Dial() (*dbus.Conn, error) {
+	return dbus.Connect(b.Address)
+}
+
+// Clients returns the clients of the system services connected to the bus, closed when the
+// test ends.
+func (b *Bus) Clients(t testing.TB) *bus.Clients {
+	c := bus.NewConn()
+	c.Dial = b.Dial
+	c.Timeout = WaitTimeout
+
+	t.Cleanup(c.Close)
+
+	return bus.NewClients(c)
+}
+
+// Call is a method call received by a fake.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Service is a fake owning a name on the bus. It records the method calls it receives and
+// fails the ones it was told to.
+type Service struct {
+	Name string
+
+	conn *dbus.Conn
+
+	mutex   sync.Mutex
+	calls   []Call
+	errors  map[string]*dbus.Error
+	changed chan struct{}
+}
+
+func newService(t testing.TB, b *Bus, name string) *Service {
+	t.Helper()
+
+	conn, err := b.Dial()
+	if err != nil {
+		t.Fatalf("Failed to connect '%s' to the bus: %v", name, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("Failed to own name '%s': %v", name, err)
+	}
+
+	return &Service{
+		Name:    name,
+		conn:    conn,
+		errors:  make(map[string]*dbus.Error),
+		changed: make(chan struct{}),
+	}
+}
+
+// export exports the methods on the interface of the object. Each method is recorded before it
+// runs, methods told to fail return their error instead of running.
+func (s *Service) export(t testing.TB, path dbus.ObjectPath, iface string, methods map[string]interface{}) {
+	t.Helper()
+
+	table := make(map[string]interface{}, len(methods))
+	for name, m := range methods {
+		table[name] = s.wrap(name, m)
+	}
+
+	if err := s.conn.ExportMethodTable(table, path, iface); err != nil {
+		t.Fatalf("Failed to export '%s' on '%s': %v", iface, path, err)
+	}
+}
+
+func (s *Service) wrap(name string, method interface{}) interface{} {
+	m := reflect.ValueOf(method)
+
+	return reflect.MakeFunc(m.Type(), func(in []reflect.Value) []reflect.Value {
+		args := make([]interface{}, len(in))
+		for i, v := range in {
+			args[i] = v.Interface()
+		}
+
+		if err := s.record(name, args); err != nil {
+			out := make([]reflect.Value, m.Type().NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(m.Type().Out(i))
+			}
+			out[len(out)-1] = reflect.ValueOf(err)
+
+			return out
+		}
+
+		return m.Call(in)
+	}).Interface()
+}
+
+func (s *Service) record(method string, args []interface{}) *dbus.Error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = append(s.calls, Call{Method: method, Args: args})
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+
+	return s.errors[method]
+}
+
+// Fail makes calls of the method return err, or succeed again if err is nil.
+func (s *Service) Fail(method string, err *dbus.Error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err == nil {
+		delete(s.errors, method)
+		return
+	}
+
+	s.errors[method] = err
+}
+
+// Calls returns the calls of the method received so far, all calls if method is an empty
+// string.
+func (s *Service) Calls(method string) []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// WaitCalls waits until the method was called at least count times and returns the calls.
+func (s *Service) WaitCalls(t testing.TB, method string, count int) []Call {
+	t.Helper()
+
+	timeout := time.After(WaitTimeout)
+	for {
+		s.mutex.Lock()
+		changed := s.changed
+		s.mutex.Unlock()
+
+		if calls := s.Calls(method); len(calls) >= count {
+			return calls
+		}
+
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %d calls of %s.%s, got %+v", count, s.Name, method, s.Calls(""))
+			return nil
+		}
+	}
+}
+
+// WaitCall waits for the first call of the method.
+func (s *Service) WaitCall(t testing.TB, method string) Call {
+	t.Helper()
+
+	return s.WaitCalls(t, method, 1)[0]
+}
+
+// EmitPropertiesChanged emits PropertiesChanged of the interface of the object.
+func (s *Service) EmitPropertiesChanged(path dbus.ObjectPath, iface string, changed map[string]dbus.Variant) error {
+	return s.conn.Emit(path, bus.DBusProperties+".PropertiesChanged", iface, changed, []string{})
+}
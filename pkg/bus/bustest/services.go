@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bustest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+)
+
+// Networkd fakes the Manager of systemd-networkd and emits the signals of its links.
+type Networkd struct {
+	*Service
+
+	// Description is what Describe returns, set before it is called
+	Description string
+}
+
+func NewNetworkd(t testing.TB, b *Bus) *Networkd {
+	n := &Networkd{
+		Service:     newService(t, b, bus.NetworkInterface),
+		Description: `{"Interfaces":[]}`,
+	}
+
+	n.export(t, bus.NetworkObjectPath, bus.NetworkManager, map[string]interface{}{
+		"Describe":        func() (string, *dbus.Error) { return n.Description, nil },
+		"ReconfigureLink": func(index int32) *dbus.Error { return nil },
+		"Reload":          func() *dbus.Error { return nil },
+		"RenewLink":       func(index int32) *dbus.Error { return nil },
+		"ForceRenewLink":  func(index int32) *dbus.Error { return nil },
+		"SetLinkDNS":      func(index int32, dns []bus.DnsServer) *dbus.Error { return nil },
+		"RevertLinkDNS":   func(index int32) *dbus.Error { return nil },
+		"SetLinkNTP":      func(index int32, servers []string) *dbus.Error { return nil },
+		"RevertLinkNTP":   func(index int32) *dbus.Error { return nil },
+	})
+
+	return n
+}
+
+// LinkPath returns the object of a link, whose ifindex is escaped as systemd does.
+func LinkPath(index int) dbus.ObjectPath {
+	return dbus.ObjectPath(bus.NetworkObjectPath + "/link/_3" + strconv.Itoa(index))
+}
+
+// EmitLinkProperties emits changed properties of a link, e.g. its OperationalState.
+func (n *Networkd) EmitLinkProperties(index int, changed map[string]dbus.Variant) error {
+	return n.EmitPropertiesChanged(LinkPath(index), bus.NetworkInterface+".Link", changed)
+}
+
+// EmitManagerProperties emits changed properties of the Manager.
+func (n *Networkd) EmitManagerProperties(changed map[string]dbus.Variant) error {
+	return n.EmitPropertiesChanged(bus.NetworkObjectPath, bus.NetworkManager, changed)
+}
+
+// Resolved fakes the Manager of systemd-resolved.
+type Resolved struct {
+	*Service
+}
+
+func NewResolved(t testing.TB, b *Bus) *Resolved {
+	r := &Resolved{
+		Service: newService(t, b, bus.ResolveInterface),
+	}
+
+	r.export(t, bus.ResolveObjectPath, bus.ResolveManager, map[string]interface{}{
+		"SetLinkDNS":          func(index int32, dns []bus.DnsServer) *dbus.Error { return nil },
+		"SetLinkDNSEx":        func(index int32, dns []bus.DnsServerEx) *dbus.Error { return nil },
+		"SetLinkDomains":      func(index int32, domains []bus.Domain) *dbus.Error { return nil },
+		"SetLinkDNSOverTLS":   func(index int32, mode string) *dbus.Error { return nil },
+		"SetLinkDNSSEC":       func(index int32, mode string) *dbus.Error { return nil },
+		"SetLinkLLMNR":        func(index int32, mode string) *dbus.Error { return nil },
+		"SetLinkMulticastDNS": func(index int32, mode string) *dbus.Error { return nil },
+		"SetLinkDefaultRoute": func(index int32, enable bool) *dbus.Error { return nil },
+		"RevertLink":          func(index int32) *dbus.Error { return nil },
+	})
+
+	return r
+}
+
+// Timesyncd fakes the Manager of systemd-timesyncd.
+type Timesyncd struct {
+	*Service
+}
+
+func NewTimesyncd(t testing.TB, b *Bus) *Timesyncd {
+	ts := &Timesyncd{
+		Service: newService(t, b, bus.TimesyncInterface),
+	}
+
+	ts.export(t, bus.TimesyncObjectPath, bus.TimesyncManager, map[string]interface{}{
+		"SetRuntimeNTPServers": func(servers []string) *dbus.Error { return nil },
+	})
+
+	return ts
+}
+
+// Hostnamed fakes systemd-hostnamed. The hostnames set are returned by its properties.
+type Hostnamed struct {
+	*Service
+
+	properties map[string]string
+}
+
+// NewHostnamed starts the fake with the transient hostname.
+func NewHostnamed(t testing.TB, b *Bus, hostname string) *Hostnamed {
+	h := &Hostnamed{
+		Service: newService(t, b, bus.HostnameInterface),
+		properties: map[string]string{
+			"Hostname":       hostname,
+			"StaticHostname": "",
+			"PrettyHostname": "",
+		},
+	}
+
+	h.export(t, bus.HostnameObjectPath, bus.HostnameInterface, map[string]interface{}{
+		"SetHostname":       func(name string, interactive bool) *dbus.Error { return h.set("Hostname", name) },
+		"SetStaticHostname": func(name string, interactive bool) *dbus.Error { return h.set("StaticHostname", name) },
+		"SetPrettyHostname": func(name string, interactive bool) *dbus.Error { return h.set("PrettyHostname", name) },
+	})
+
+	h.export(t, bus.HostnameObjectPath, bus.DBusProperties, map[string]interface{}{
+		"Get": func(iface string, property string) (dbus.Variant, *dbus.Error) {
+			h.mutex.Lock()
+			defer h.mutex.Unlock()
+
+			v, ok := h.properties[property]
+			if iface != bus.HostnameInterface || !ok {
+				return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{property})
+			}
+
+			return dbus.MakeVariant(v), nil
+		},
+		"GetAll": func(iface string) (map[string]dbus.Variant, *dbus.Error) {
+			h.mutex.Lock()
+			defer h.mutex.Unlock()
+
+			m := make(map[string]dbus.Variant, len(h.properties))
+			for k, v := range h.properties {
+				m[k] = dbus.MakeVariant(v)
+			}
+
+			return m, nil
+		},
+	})
+
+	return h
+}
+
+func (h *Hostnamed) set(property string, hostname string) *dbus.Error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.properties[property] = hostname
+
+	return nil
+}
+
+// Property returns the current value of Hostname, StaticHostname or PrettyHostname.
+func (h *Hostnamed) Property(property string) string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.properties[property]
+}
@@ -4,9 +4,14 @@
 package bus
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	log "github.com/sirupsen/logrus"
@@ -15,26 +20,13 @@ import (
 const (
 	DBusProperties = "org.freedesktop.DBus.Properties"
 
-	resolveInterface      = "org.freedesktop.resolve1"
-	resolveObjectPath     = "/org/freedesktop/resolve1"
-	resolveSetLinkDNS     = resolveInterface + ".Manager.SetLinkDNS"
-	resolveSetLinkDomains = resolveInterface + ".Manager.SetLinkDomains"
-	resolveReventLink     = resolveInterface + ".Revert"
+	dbusNameHasOwner = "org.freedesktop.DBus.NameHasOwner"
 
-	hostnameInterface   = "org.freedesktop.hostname1"
-	hostnameObjectPath  = "/org/freedesktop/hostname1"
-	hostnameSetHostname = hostnameInterface + ".SetStaticHostname"
-)
-
-type DnsServer struct {
-	Family  int32
-	Address []byte
-}
+	// DefaultTimeout bounds calls whose context has no deadline
+	DefaultTimeout = 10 * time.Second
 
-type Domain struct {
-	Domain string
-	Set    bool
-}
+	reconnectInterval = 5 * time.Second
+)
 
 func SystemBusPrivateConn() (*dbus.Conn, error) {
 	conn, err := dbus.SystemBusPrivate()
@@ -44,96 +36,246 @@ func SystemBusPrivateConn() (*dbus.Conn, error) {
 
 	methods := []dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}
 
-	err = conn.Auth(methods)
-	if err != nil {
+	if err := conn.Auth(methods); err != nil {
 		conn.Close()
-		conn = nil
-		return conn, err
+		return nil, err
 	}
 
-	if err = conn.Hello(); err != nil {
+	if err := conn.Hello(); err != nil {
 		conn.Close()
-		conn = nil
+		return nil, err
 	}
 
 	return conn, nil
 }
 
-func SetResolveDNS(dns []DnsServer, index int) error {
-	conn, err := dbus.ConnectSystemBus()
-	if err != nil {
-		return fmt.Errorf("failed to connect to system bus: %v", err)
+type subscription struct {
+	options []dbus.MatchOption
+	ch      chan<- *dbus.Signal
+}
+
+// Conn is a private connection to the system bus shared by all clients. It is established on
+// first use and again when it was lost. Signal subscriptions are renewed on reconnect.
+type Conn struct {
+	// Dial opens the connection, SystemBusPrivateConn unless replaced e.g. by a test.
+	Dial    func() (*dbus.Conn, error)
+	Timeout time.Duration
+
+	mutex         sync.Mutex
+	conn          *dbus.Conn
+	closed        bool
+	subscriptions []*subscription
+
+	// Closed by Close to stop the forwarders of the subscriptions
+	done       chan struct{}
+	forwarders sync.WaitGroup
+}
+
+func NewConn() *Conn {
+	return &Conn{
+		Dial:    SystemBusPrivateConn,
+		Timeout: DefaultTimeout,
+		done:    make(chan struct{}),
+	}
+}
+
+// get returns the connection, reconnecting if it was lost.
+func (c *Conn) get() (*dbus.Conn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, dbus.ErrClosed
 	}
-	defer conn.Close()
 
-	log.Debugf("Setting DNS servers ifindex='%d'", index)
+	if c.conn != nil && c.conn.Connected() {
+		return c.conn, nil
+	}
 
-	obj := conn.Object(resolveInterface, resolveObjectPath)
-	err = obj.Call(resolveSetLinkDNS, 0, index, dns).Store()
+	if c.conn != nil {
+		log.Infoln("Connection to the system bus lost, reconnecting")
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	conn, err := c.Dial()
 	if err != nil {
-		return fmt.Errorf("failed to set DNS servers: %w", err)
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	for _, s := range c.subscriptions {
+		if err := c.subscribe(conn, s); err != nil {
+			conn.Close()
+			return nil, err
+		}
 	}
 
-	log.Debugf("Successfully set DNS servers ifindex='%d'", index)
+	c.conn = conn
 
-	return nil
+	return conn, nil
 }
 
-func SetResolveDomain(dnsDomains []Domain, index int) error {
-	conn, err := dbus.ConnectSystemBus()
-	if err != nil {
-		return fmt.Errorf("failed to connect to system bus: %v", err)
+func (c *Conn) subscribe(conn *dbus.Conn, s *subscription) error {
+	if err := conn.AddMatchSignal(s.options...); err != nil {
+		return fmt.Errorf("failed to add match signal: %w", err)
 	}
-	defer conn.Close()
 
-	log.Debugf("Setting DNS domains ifindex='%d'", index)
+	// godbus closes the channel of a connection going away, so forward from one per connection
+	ch := make(chan *dbus.Signal, cap(s.ch))
+	conn.Signal(ch)
 
-	obj := conn.Object(resolveInterface, resolveObjectPath)
-	err = obj.Call(resolveSetLinkDomains, 0, index, dnsDomains).Store()
-	if err != nil {
-		return fmt.Errorf("failed to set DNS domains: %+v: %v", dnsDomains, err)
-	}
+	c.forwarders.Add(1)
+	go func() {
+		defer c.forwarders.Done()
 
-	log.Debugf("Successfully set DNS domain ifindex='%d'", index)
+		for v := range ch {
+			select {
+			case s.ch <- v:
+			case <-c.done:
+				return
+			}
+		}
+	}()
 
 	return nil
 }
 
-func RevertDNSLink(index int) error {
-	conn, err := dbus.ConnectSystemBus()
-	if err != nil {
-		return fmt.Errorf("failed to connect to system bus: %v", err)
+// Subscribe delivers the signals matching the options to ch, across reconnects. ch is closed
+// when the connection is closed.
+func (c *Conn) Subscribe(ch chan<- *dbus.Signal, options ...dbus.MatchOption) error {
+	s := &subscription{
+		options: options,
+		ch:      ch,
 	}
-	defer conn.Close()
 
-	log.Debugf("Reverting DNS domains ifindex='%d'", index)
+	c.mutex.Lock()
+	conn := c.conn
+	if conn != nil && conn.Connected() {
+		if err := c.subscribe(conn, s); err != nil {
+			c.mutex.Unlock()
+			return err
+		}
+	}
+	c.subscriptions = append(c.subscriptions, s)
+	c.mutex.Unlock()
 
-	obj := conn.Object(resolveInterface, resolveObjectPath)
-	err = obj.Call(resolveReventLink, 0, index, 0).Store()
-	if err != nil {
-		return fmt.Errorf("failed to revert link='%d' DNS: %v", index, err)
+	// A new connection subscribes all
+	_, err := c.get()
+
+	return err
+}
+
+// Watch reconnects when the connection is lost until ctx is done, so that subscriptions keep
+// delivering signals without waiting for the next call.
+func (c *Conn) Watch(ctx context.Context) {
+	ticker := time.NewTicker(reconnectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.get(); err != nil && !errors.Is(err, dbus.ErrClosed) {
+				log.Debugln(err)
+			}
+		}
 	}
+}
 
-	log.Debugf("Successfully revert DNS ifindex='%d'", index)
-	return nil
+// Call calls a method and stores its reply in ret. A call failing as the connection was lost
+// is retried once on a new connection.
+func (c *Conn) Call(ctx context.Context, dest string, path dbus.ObjectPath, method string, args []interface{}, ret ...interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var err error
+	for i := 0; i < 2; i++ {
+		var conn *dbus.Conn
+		conn, err = c.get()
+		if err != nil {
+			return err
+		}
+
+		err = conn.Object(dest, path).CallWithContext(ctx, method, 0, args...).Store(ret...)
+		if err == nil || conn.Connected() {
+			return err
+		}
+	}
+
+	return err
 }
 
-func SetHostname(hostname string) error {
-	conn, err := dbus.ConnectSystemBus()
-	if err != nil {
-		return fmt.Errorf("failed to connect to system bus: %v", err)
+// GetProperty returns a property of an object.
+func (c *Conn) GetProperty(ctx context.Context, dest string, path dbus.ObjectPath, property string) (dbus.Variant, error) {
+	var v dbus.Variant
+
+	i := strings.LastIndex(property, ".")
+	err := c.Call(ctx, dest, path, DBusProperties+".Get", []interface{}{property[:i], property[i+1:]}, &v)
+
+	return v, err
+}
+
+// NameHasOwner reports whether a service is running.
+func (c *Conn) NameHasOwner(ctx context.Context, name string) (bool, error) {
+	var running bool
+
+	err := c.Call(ctx, "org.freedesktop.DBus", "/org/freedesktop/DBus", dbusNameHasOwner, []interface{}{name}, &running)
+
+	return running, err
+}
+
+// Close closes the connection for good and the channels of the subscriptions, which ends the
+// watchers reading them.
+func (c *Conn) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
 	}
-	defer conn.Close()
 
-	log.Debugf("Setting hostname='%s'", hostname)
+	c.closed = true
+	close(c.done)
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
 
-	obj := conn.Object(hostnameInterface, hostnameObjectPath)
-	err = obj.Call(hostnameSetHostname, 0, hostname, true).Store()
-	if err != nil {
-		return fmt.Errorf("failed to set hostname: %w", err)
+	// No forwarder sends on a channel once they are all gone
+	c.forwarders.Wait()
+	closed := make(map[chan<- *dbus.Signal]bool)
+	for _, s := range c.subscriptions {
+		if !closed[s.ch] {
+			closed[s.ch] = true
+			close(s.ch)
+		}
 	}
+	c.subscriptions = nil
+}
 
-	log.Debugln("Successfully set hostname")
+// Clients are the typed clients of the system services sharing one connection.
+type Clients struct {
+	Conn      *Conn
+	Networkd  Networkd
+	Resolved  Resolved
+	Hostnamed Hostnamed
+	Timesyncd Timesyncd
+	NTP       NTPServers
+}
 
-	return nil
+func NewClients(c *Conn) *Clients {
+	b := &Clients{
+		Conn:      c,
+		Networkd:  NewNetworkd(c),
+		Resolved:  NewResolved(c),
+		Hostnamed: NewHostnamed(c),
+		Timesyncd: NewTimesyncd(c),
+	}
+	b.NTP = NewNTPServers(b.Networkd, b.Timesyncd)
+
+	return b
 }
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/bus/bustest"
+)
+
+func TestConnSubscribe(t *testing.T) {
+	b := bustest.NewBus(t)
+	networkd := bustest.NewNetworkd(t, b)
+	conn := b.Clients(t).Conn
+
+	ch := make(chan *dbus.Signal, 16)
+	if err := conn.Subscribe(ch, dbus.WithMatchSender(bus.NetworkInterface), dbus.WithMatchMember("PropertiesChanged")); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := networkd.EmitManagerProperties(map[string]dbus.Variant{"OperationalState": dbus.MakeVariant("routable")}); err != nil {
+		t.Fatalf("Failed to emit signal: %v", err)
+	}
+
+	select {
+	case v := <-ch:
+		if v.Name != bus.DBusProperties+".PropertiesChanged" {
+			t.Errorf("Received signal='%s', want PropertiesChanged", v.Name)
+		}
+	case <-time.After(bustest.WaitTimeout):
+		t.Fatalf("Timed out waiting for the signal")
+	}
+
+	// Closing the connection ends the readers of the subscriptions
+	conn.Close()
+	conn.Close()
+
+	timeout := time.After(bustest.WaitTimeout)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Channel of the subscription not closed")
+		}
+	}
+}
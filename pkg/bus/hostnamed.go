@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	HostnameInterface  = "org.freedesktop.hostname1"
+	HostnameObjectPath = "/org/freedesktop/hostname1"
+)
+
+// Hostnamed is the client of systemd-hostnamed.
+type Hostnamed interface {
+	SetHostname(ctx context.Context, hostname string) error
+	SetStaticHostname(ctx context.Context, hostname string) error
+	SetPrettyHostname(ctx context.Context, hostname string) error
+	Hostname(ctx context.Context) (string, error)
+	StaticHostname(ctx context.Context) (string, error)
+	PrettyHostname(ctx context.Context) (string, error)
+}
+
+type hostnamed struct {
+	conn *Conn
+}
+
+func NewHostnamed(c *Conn) Hostnamed {
+	return &hostnamed{conn: c}
+}
+
+func (h *hostnamed) set(ctx context.Context, method string, hostname string) error {
+	log.Debugf("Calling %s hostname='%s'", method, hostname)
+
+	err := h.conn.Call(ctx, HostnameInterface, dbus.ObjectPath(HostnameObjectPath), HostnameInterface+"."+method, []interface{}{hostname, false})
+	if err != nil {
+		return fmt.Errorf("failed to call %s hostname='%s': %w", method, hostname, err)
+	}
+
+	return nil
+}
+
+func (h *hostnamed) get(ctx context.Context, property string) (string, error) {
+	v, err := h.conn.GetProperty(ctx, HostnameInterface, dbus.ObjectPath(HostnameObjectPath), HostnameInterface+"."+property)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", property, err)
+	}
+
+	hostname, ok := v.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected %s type '%s'", property, v.Signature())
+	}
+
+	return hostname, nil
+}
+
+// SetHostname sets the transient hostname, which is used while no static hostname is set.
+func (h *hostnamed) SetHostname(ctx context.Context, hostname string) error {
+	return h.set(ctx, "SetHostname", hostname)
+}
+
+// SetStaticHostname writes /etc/hostname.
+func (h *hostnamed) SetStaticHostname(ctx context.Context, hostname string) error {
+	return h.set(ctx, "SetStaticHostname", hostname)
+}
+
+func (h *hostnamed) SetPrettyHostname(ctx context.Context, hostname string) error {
+	return h.set(ctx, "SetPrettyHostname", hostname)
+}
+
+func (h *hostnamed) Hostname(ctx context.Context) (string, error) {
+	return h.get(ctx, "Hostname")
+}
+
+func (h *hostnamed) StaticHostname(ctx context.Context) (string, error) {
+	return h.get(ctx, "StaticHostname")
+}
+
+func (h *hostnamed) PrettyHostname(ctx context.Context) (string, error) {
+	return h.get(ctx, "PrettyHostname")
+}
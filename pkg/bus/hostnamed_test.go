@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/network-event-broker/pkg/bus/bustest"
+)
+
+func TestHostnamed(t *testing.T) {
+	b := bustest.NewBus(t)
+	fake := bustest.NewHostnamed(t, b, "localhost")
+	h := b.Clients(t).Hostnamed
+	ctx := context.Background()
+
+	if got, err := h.Hostname(ctx); err != nil || got != "localhost" {
+		t.Fatalf("Hostname='%s' err='%v', want 'localhost'", got, err)
+	}
+
+	if got, err := h.StaticHostname(ctx); err != nil || got != "" {
+		t.Fatalf("StaticHostname='%s' err='%v', want none", got, err)
+	}
+
+	for _, s := range []struct {
+		method   string
+		set      func(context.Context, string) error
+		get      func(context.Context) (string, error)
+		hostname string
+	}{
+		{"SetHostname", h.SetHostname, h.Hostname, "node1"},
+		{"SetStaticHostname", h.SetStaticHostname, h.StaticHostname, "node2"},
+		{"SetPrettyHostname", h.SetPrettyHostname, h.PrettyHostname, "node1.example.com"},
+	} {
+		if err := s.set(ctx, s.hostname); err != nil {
+			t.Fatalf("%s failed: %v", s.method, err)
+		}
+
+		// Not interactive, network-broker cannot answer a polkit prompt
+		call := fake.WaitCall(t, s.method)
+		if want := []interface{}{s.hostname, false}; !reflect.DeepEqual(call.Args, want) {
+			t.Errorf("%s args='%v', want '%v'", s.method, call.Args, want)
+		}
+
+		if got, err := s.get(ctx); err != nil || got != s.hostname {
+			t.Errorf("Hostname after %s='%s' err='%v', want '%s'", s.method, got, err, s.hostname)
+		}
+	}
+}
+
+func TestHostnamedFailure(t *testing.T) {
+	b := bustest.NewBus(t)
+	fake := bustest.NewHostnamed(t, b, "localhost")
+	h := b.Clients(t).Hostnamed
+
+	fake.Fail("SetHostname", errUnknownMethod)
+
+	if err := h.SetHostname(context.Background(), "node1"); err == nil {
+		t.Fatalf("SetHostname succeeded while hostnamed failed")
+	}
+
+	if got := fake.Property("Hostname"); got != "localhost" {
+		t.Errorf("Hostname='%s' after a failed call, want 'localhost'", got)
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	NetworkInterface  = "org.freedesktop.network1"
+	NetworkObjectPath = "/org/freedesktop/network1"
+	NetworkManager    = NetworkInterface + ".Manager"
+)
+
+// Networkd is the client of the Manager of systemd-networkd.
+type Networkd interface {
+	Describe(ctx context.Context) (string, error)
+	ReconfigureLink(ctx context.Context, index int) error
+	Reload(ctx context.Context) error
+	RenewLink(ctx context.Context, index int) error
+	ForceRenewLink(ctx context.Context, index int) error
+	SetLinkDNS(ctx context.Context, index int, dns []DnsServer) error
+	RevertLinkDNS(ctx context.Context, index int) error
+	SetLinkNTP(ctx context.Context, index int, servers []string) error
+	RevertLinkNTP(ctx context.Context, index int) error
+}
+
+type networkd struct {
+	conn *Conn
+}
+
+func NewNetworkd(c *Conn) Networkd {
+	return &networkd{conn: c}
+}
+
+func (n *networkd) call(ctx context.Context, method string, args []interface{}, ret ...interface{}) error {
+	return n.conn.Call(ctx, NetworkInterface, dbus.ObjectPath(NetworkObjectPath), NetworkManager+"."+method, args, ret...)
+}
+
+// Describe returns the JSON description of all links.
+func (n *networkd) Describe(ctx context.Context) (string, error) {
+	var props string
+	if err := n.call(ctx, "Describe", nil, &props); err != nil {
+		return "", err
+	}
+
+	return props, nil
+}
+
+func (n *networkd) ReconfigureLink(ctx context.Context, index int) error {
+	if err := n.call(ctx, "ReconfigureLink", []interface{}{index}); err != nil {
+		return fmt.Errorf("failed to reconfigure link ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+func (n *networkd) Reload(ctx context.Context) error {
+	if err := n.call(ctx, "Reload", nil); err != nil {
+		return fmt.Errorf("failed to reload systemd-networkd: %w", err)
+	}
+
+	return nil
+}
+
+// RenewLink renews the DHCPv4 lease of the link.
+func (n *networkd) RenewLink(ctx context.Context, index int) error {
+	if err := n.call(ctx, "RenewLink", []interface{}{index}); err != nil {
+		return fmt.Errorf("failed to renew link ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+// ForceRenewLink makes the DHCPv4 server of the link send FORCERENEW to its clients.
+func (n *networkd) ForceRenewLink(ctx context.Context, index int) error {
+	if err := n.call(ctx, "ForceRenewLink", []interface{}{index}); err != nil {
+		return fmt.Errorf("failed to force renew link ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+// SetLinkDNS sets runtime DNS servers of the link, which systemd-networkd hands on to
+// systemd-resolved.
+func (n *networkd) SetLinkDNS(ctx context.Context, index int, dns []DnsServer) error {
+	if err := n.call(ctx, "SetLinkDNS", []interface{}{index, dns}); err != nil {
+		return fmt.Errorf("failed to set DNS servers of link ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+func (n *networkd) RevertLinkDNS(ctx context.Context, index int) error {
+	if err := n.call(ctx, "RevertLinkDNS", []interface{}{index}); err != nil {
+		return fmt.Errorf("failed to revert DNS servers of link ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+func (n *networkd) SetLinkNTP(ctx context.Context, index int, servers []string) error {
+	return n.call(ctx, "SetLinkNTP", []interface{}{index, servers})
+}
+
+func (n *networkd) RevertLinkNTP(ctx context.Context, index int) error {
+	return n.call(ctx, "RevertLinkNTP", []interface{}{index})
+}
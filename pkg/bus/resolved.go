@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ResolveInterface  = "org.freedesktop.resolve1"
+	ResolveObjectPath = "/org/freedesktop/resolve1"
+	ResolveManager    = ResolveInterface + ".Manager"
+)
+
+type DnsServer struct {
+	Family  int32
+	Address []byte
+}
+
+// DnsServerEx is a DNS server with an optional port and server name used for SNI.
+type DnsServerEx struct {
+	Family  int32
+	Address []byte
+	Port    uint16
+	Name    string
+}
+
+// ParseDnsServer parses a DNS server in the format of resolved.conf(5), i.e.
+// 'address[:port][%interface][#name]' where IPv6 addresses with a port are written in brackets.
+func ParseDnsServer(s string) (*DnsServerEx, error) {
+	d := &DnsServerEx{}
+
+	s, d.Name, _ = strings.Cut(s, "#")
+
+	host := s
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in DNS server '%s': %v", s, err)
+		}
+
+		host = h
+		d.Port = uint16(port)
+	}
+
+	// The interface is given by the ifindex of the call
+	host, _, _ = strings.Cut(strings.Trim(host, "[]"), "%")
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid DNS server address '%s'", s)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		d.Family = unix.AF_INET
+		d.Address = []byte(ip4)
+	} else {
+		d.Family = unix.AF_INET6
+		d.Address = []byte(ip.To16())
+	}
+
+	return d, nil
+}
+
+// Domain is a search domain, or a routing-only domain used only to route lookups to the link.
+type Domain struct {
+	Domain      string
+	RoutingOnly bool
+}
+
+// Resolved is the client of the per link settings of systemd-resolved.
+type Resolved interface {
+	Running(ctx context.Context) bool
+	SetLinkDNS(ctx context.Context, index int, dns []DnsServer) error
+	SetLinkDNSEx(ctx context.Context, index int, dns []DnsServerEx) error
+	SetLinkDomains(ctx context.Context, index int, domains []Domain) error
+	SetLinkDNSOverTLS(ctx context.Context, index int, mode string) error
+	SetLinkDNSSEC(ctx context.Context, index int, mode string) error
+	SetLinkLLMNR(ctx context.Context, index int, mode string) error
+	SetLinkMulticastDNS(ctx context.Context, index int, mode string) error
+	SetLinkDefaultRoute(ctx context.Context, index int, enable bool) error
+	RevertLink(ctx context.Context, index int) error
+}
+
+type resolved struct {
+	conn *Conn
+}
+
+func NewResolved(c *Conn) Resolved {
+	return &resolved{conn: c}
+}
+
+func (r *resolved) call(ctx context.Context, method string, args ...interface{}) error {
+	return r.conn.Call(ctx, ResolveInterface, dbus.ObjectPath(ResolveObjectPath), ResolveManager+"."+method, args)
+}
+
+// Running reports whether systemd-resolved owns its name on the system bus.
+func (r *resolved) Running(ctx context.Context) bool {
+	running, err := r.conn.NameHasOwner(ctx, ResolveInterface)
+	if err != nil {
+		log.Debugf("Failed to look up '%s': %v", ResolveInterface, err)
+		return false
+	}
+
+	return running
+}
+
+func (r *resolved) SetLinkDNS(ctx context.Context, index int, dns []DnsServer) error {
+	log.Debugf("Setting DNS servers ifindex='%d'", index)
+
+	if err := r.call(ctx, "SetLinkDNS", index, dns); err != nil {
+		return fmt.Errorf("failed to set DNS servers: %w", err)
+	}
+
+	log.Debugf("Successfully set DNS servers ifindex='%d'", index)
+
+	return nil
+}
+
+// SetLinkDNSEx sets DNS servers with port and server name. Requires systemd-resolved 246
+// or newer.
+func (r *resolved) SetLinkDNSEx(ctx context.Context, index int, dns []DnsServerEx) error {
+	log.Debugf("Setting extended DNS servers ifindex='%d'", index)
+
+	if err := r.call(ctx, "SetLinkDNSEx", index, dns); err != nil {
+		return fmt.Errorf("failed to set DNS servers: %w", err)
+	}
+
+	log.Debugf("Successfully set extended DNS servers ifindex='%d'", index)
+
+	return nil
+}
+
+func (r *resolved) SetLinkDomains(ctx context.Context, index int, domains []Domain) error {
+	log.Debugf("Setting DNS domains ifindex='%d'", index)
+
+	if err := r.call(ctx, "SetLinkDomains", index, domains); err != nil {
+		return fmt.Errorf("failed to set DNS domains: %+v: %w", domains, err)
+	}
+
+	log.Debugf("Successfully set DNS domain ifindex='%d'", index)
+
+	return nil
+}
+
+// SetLinkDNSOverTLS takes 'yes', 'no', 'opportunistic' or an empty string to use the global setting.
+func (r *resolved) SetLinkDNSOverTLS(ctx context.Context, index int, mode string) error {
+	if err := r.call(ctx, "SetLinkDNSOverTLS", index, mode); err != nil {
+		return fmt.Errorf("failed to set DNSOverTLS='%s' ifindex='%d': %w", mode, index, err)
+	}
+
+	return nil
+}
+
+// SetLinkDNSSEC takes 'yes', 'no', 'allow-downgrade' or an empty string to use the global setting.
+func (r *resolved) SetLinkDNSSEC(ctx context.Context, index int, mode string) error {
+	if err := r.call(ctx, "SetLinkDNSSEC", index, mode); err != nil {
+		return fmt.Errorf("failed to set DNSSEC='%s' ifindex='%d': %w", mode, index, err)
+	}
+
+	return nil
+}
+
+// SetLinkLLMNR takes 'yes', 'no', 'resolve' or an empty string to use the global setting.
+func (r *resolved) SetLinkLLMNR(ctx context.Context, index int, mode string) error {
+	if err := r.call(ctx, "SetLinkLLMNR", index, mode); err != nil {
+		return fmt.Errorf("failed to set LLMNR='%s' ifindex='%d': %w", mode, index, err)
+	}
+
+	return nil
+}
+
+// SetLinkMulticastDNS takes 'yes', 'no', 'resolve' or an empty string to use the global setting.
+func (r *resolved) SetLinkMulticastDNS(ctx context.Context, index int, mode string) error {
+	if err := r.call(ctx, "SetLinkMulticastDNS", index, mode); err != nil {
+		return fmt.Errorf("failed to set MulticastDNS='%s' ifindex='%d': %w", mode, index, err)
+	}
+
+	return nil
+}
+
+// SetLinkDefaultRoute sets whether the link is used for domains not matching any routing domain.
+func (r *resolved) SetLinkDefaultRoute(ctx context.Context, index int, enable bool) error {
+	if err := r.call(ctx, "SetLinkDefaultRoute", index, enable); err != nil {
+		return fmt.Errorf("failed to set DefaultRoute='%t' ifindex='%d': %w", enable, index, err)
+	}
+
+	return nil
+}
+
+func (r *resolved) RevertLink(ctx context.Context, index int) error {
+	log.Debugf("Reverting DNS settings ifindex='%d'", index)
+
+	if err := r.call(ctx, "RevertLink", index); err != nil {
+		return fmt.Errorf("failed to revert link='%d' DNS: %w", index, err)
+	}
+
+	log.Debugf("Successfully reverted DNS ifindex='%d'", index)
+
+	return nil
+}
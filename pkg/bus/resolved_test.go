@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+)
+
+func TestParseDnsServer(t *testing.T) {
+	v4 := []byte(net.ParseIP("192.0.2.53").To4())
+	v6 := []byte(net.ParseIP("2001:db8::53"))
+
+	tests := []struct {
+		s    string
+		want *bus.DnsServerEx
+	}{
+		{"192.0.2.53", &bus.DnsServerEx{Family: unix.AF_INET, Address: v4}},
+		{"192.0.2.53:5353", &bus.DnsServerEx{Family: unix.AF_INET, Address: v4, Port: 5353}},
+		{"192.0.2.53#dns.example.com", &bus.DnsServerEx{Family: unix.AF_INET, Address: v4, Name: "dns.example.com"}},
+		{"192.0.2.53:853#dns.example.com", &bus.DnsServerEx{Family: unix.AF_INET, Address: v4, Port: 853, Name: "dns.example.com"}},
+		{"2001:db8::53", &bus.DnsServerEx{Family: unix.AF_INET6, Address: v6}},
+		{"[2001:db8::53]:853", &bus.DnsServerEx{Family: unix.AF_INET6, Address: v6, Port: 853}},
+		{"2001:db8::53%eth0", &bus.DnsServerEx{Family: unix.AF_INET6, Address: v6}},
+		{"[2001:db8::53%eth0]:53#dns.example.com", &bus.DnsServerEx{Family: unix.AF_INET6, Address: v6, Port: 53, Name: "dns.example.com"}},
+
+		{"", nil},
+		{"dns.example.com", nil},
+		{"192.0.2.300", nil},
+		{"192.0.2.53:65536", nil},
+		{"192.0.2.53:dns", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := bus.ParseDnsServer(tt.s)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseDnsServer('%s')=%+v, want error", tt.s, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseDnsServer('%s') failed: %v", tt.s, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDnsServer('%s')=%+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
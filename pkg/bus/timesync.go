@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TimesyncInterface  = "org.freedesktop.timesync1"
+	TimesyncObjectPath = "/org/freedesktop/timesync1"
+	TimesyncManager    = TimesyncInterface + ".Manager"
+)
+
+// Timesyncd is the client of systemd-timesyncd.
+type Timesyncd interface {
+	SetRuntimeNTPServers(ctx context.Context, servers []string) error
+}
+
+type timesyncd struct {
+	conn *Conn
+}
+
+func NewTimesyncd(c *Conn) Timesyncd {
+	return &timesyncd{conn: c}
+}
+
+// SetRuntimeNTPServers requires systemd 248 or newer.
+func (t *timesyncd) SetRuntimeNTPServers(ctx context.Context, servers []string) error {
+	return t.conn.Call(ctx, TimesyncInterface, dbus.ObjectPath(TimesyncObjectPath), TimesyncManager+".SetRuntimeNTPServers", []interface{}{servers})
+}
+
+// NTPServers pushes the NTP servers of a link to systemd-timesyncd.
+type NTPServers interface {
+	SetLinkNTPServers(ctx context.Context, index int, servers []string) error
+	RevertLinkNTPServers(ctx context.Context, index int) error
+}
+
+// timesync hands the servers of links managed by systemd-networkd to it, which passes them on
+// to systemd-timesyncd just like 'timedatectl ntp-servers'. The servers of other links are
+// merged and set as the runtime servers of systemd-timesyncd.
+type timesync struct {
+	networkd  Networkd
+	timesyncd Timesyncd
+
+	mutex   sync.Mutex
+	runtime map[int][]string
+}
+
+func NewNTPServers(n Networkd, t Timesyncd) NTPServers {
+	return &timesync{
+		networkd:  n,
+		timesyncd: t,
+		runtime:   make(map[int][]string),
+	}
+}
+
+func (t *timesync) SetLinkNTPServers(ctx context.Context, index int, servers []string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	log.Debugf("Setting NTP servers ifindex='%d'", index)
+
+	err := t.networkd.SetLinkNTP(ctx, index, servers)
+	if err == nil {
+		if _, ok := t.runtime[index]; ok {
+			delete(t.runtime, index)
+			if err := t.setRuntime(ctx); err != nil {
+				log.Warnln(err)
+			}
+		}
+
+		log.Debugf("Successfully set NTP servers via systemd-networkd ifindex='%d'", index)
+		return nil
+	}
+
+	log.Debugf("Falling back to runtime NTP servers of systemd-timesyncd ifindex='%d': %v", index, err)
+
+	t.runtime[index] = servers
+	if err := t.setRuntime(ctx); err != nil {
+		delete(t.runtime, index)
+		return err
+	}
+
+	log.Debugf("Successfully set NTP servers ifindex='%d'", index)
+
+	return nil
+}
+
+func (t *timesync) RevertLinkNTPServers(ctx context.Context, index int) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	log.Debugf("Reverting NTP servers ifindex='%d'", index)
+
+	if _, ok := t.runtime[index]; ok {
+		delete(t.runtime, index)
+		return t.setRuntime(ctx)
+	}
+
+	if err := t.networkd.RevertLinkNTP(ctx, index); err != nil {
+		return fmt.Errorf("failed to revert NTP servers ifindex='%d': %w", index, err)
+	}
+
+	return nil
+}
+
+// setRuntime sets the servers of all links in the order of their ifindex.
+func (t *timesync) setRuntime(ctx context.Context) error {
+	indexes := make([]int, 0, len(t.runtime))
+	for index := range t.runtime {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	servers := []string{}
+	seen := make(map[string]bool)
+	for _, index := range indexes {
+		for _, s := range t.runtime[index] {
+			if !seen[s] {
+				seen[s] = true
+				servers = append(servers, s)
+			}
+		}
+	}
+
+	if err := t.timesyncd.SetRuntimeNTPServers(ctx, servers); err != nil {
+		return fmt.Errorf("failed to set runtime NTP servers: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package bus_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/vmware/network-event-broker/pkg/bus/bustest"
+)
+
+var errUnknownMethod = dbus.NewError("org.freedesktop.DBus.Error.UnknownMethod", []interface{}{"not supported"})
+
+// runtimeServers returns the servers of the last SetRuntimeNTPServers call.
+func runtimeServers(t *testing.T, ts *bustest.Timesyncd) []string {
+	t.Helper()
+
+	calls := ts.Calls("SetRuntimeNTPServers")
+	if len(calls) == 0 {
+		t.Fatalf("SetRuntimeNTPServers not called")
+	}
+
+	return calls[len(calls)-1].Args[0].([]string)
+}
+
+func TestNTPServersNetworkd(t *testing.T) {
+	b := bustest.NewBus(t)
+	networkd := bustest.NewNetworkd(t, b)
+	ts := bustest.NewTimesyncd(t, b)
+	ntp := b.Clients(t).NTP
+	ctx := context.Background()
+
+	if err := ntp.SetLinkNTPServers(ctx, 2, []string{"192.0.2.123"}); err != nil {
+		t.Fatalf("Failed to set NTP servers: %v", err)
+	}
+
+	call := networkd.WaitCall(t, "SetLinkNTP")
+	if want := []interface{}{int32(2), []string{"192.0.2.123"}}; !reflect.DeepEqual(call.Args, want) {
+		t.Errorf("SetLinkNTP args='%v', want '%v'", call.Args, want)
+	}
+
+	if err := ntp.RevertLinkNTPServers(ctx, 2); err != nil {
+		t.Fatalf("Failed to revert NTP servers: %v", err)
+	}
+
+	if call := networkd.WaitCall(t, "RevertLinkNTP"); call.Args[0] != int32(2) {
+		t.Errorf("RevertLinkNTP ifindex='%v', want 2", call.Args[0])
+	}
+
+	if calls := ts.Calls(""); len(calls) != 0 {
+		t.Errorf("systemd-timesyncd called while systemd-networkd took the servers: %+v", calls)
+	}
+}
+
+func TestNTPServersRuntimeFallback(t *testing.T) {
+	b := bustest.NewBus(t)
+	networkd := bustest.NewNetworkd(t, b)
+	ts := bustest.NewTimesyncd(t, b)
+	ntp := b.Clients(t).NTP
+	ctx := context.Background()
+
+	networkd.Fail("SetLinkNTP", errUnknownMethod)
+
+	// Servers are merged in the order of the ifindex, without duplicates
+	steps := []struct {
+		index   int
+		servers []string
+		want    []string
+	}{
+		{3, []string{"192.0.2.3", "192.0.2.1"}, []string{"192.0.2.3", "192.0.2.1"}},
+		{2, []string{"192.0.2.1", "192.0.2.2"}, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{3, []string{"192.0.2.4"}, []string{"192.0.2.1", "192.0.2.2", "192.0.2.4"}},
+	}
+
+	for _, s := range steps {
+		if err := ntp.SetLinkNTPServers(ctx, s.index, s.servers); err != nil {
+			t.Fatalf("Failed to set NTP servers of ifindex='%d': %v", s.index, err)
+		}
+
+		if got := runtimeServers(t, ts); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("Runtime NTP servers after setting ifindex='%d'='%v', want '%v'", s.index, got, s.want)
+		}
+	}
+
+	// Reverting a link falling back to timesyncd leaves networkd alone
+	if err := ntp.RevertLinkNTPServers(ctx, 2); err != nil {
+		t.Fatalf("Failed to revert NTP servers: %v", err)
+	}
+
+	if got, want := runtimeServers(t, ts), []string{"192.0.2.4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Runtime NTP servers after reverting='%v', want '%v'", got, want)
+	}
+
+	if calls := networkd.Calls("RevertLinkNTP"); len(calls) != 0 {
+		t.Errorf("RevertLinkNTP called for a link set via systemd-timesyncd: %+v", calls)
+	}
+
+	// Once networkd takes the servers of a link they are dropped from the runtime ones
+	networkd.Fail("SetLinkNTP", nil)
+
+	if err := ntp.SetLinkNTPServers(ctx, 3, []string{"192.0.2.4"}); err != nil {
+		t.Fatalf("Failed to set NTP servers: %v", err)
+	}
+
+	if got := runtimeServers(t, ts); len(got) != 0 {
+		t.Errorf("Runtime NTP servers after systemd-networkd took over='%v', want none", got)
+	}
+}
+
+func TestNTPServersFallbackFailure(t *testing.T) {
+	b := bustest.NewBus(t)
+	networkd := bustest.NewNetworkd(t, b)
+	ts := bustest.NewTimesyncd(t, b)
+	ntp := b.Clients(t).NTP
+	ctx := context.Background()
+
+	networkd.Fail("SetLinkNTP", errUnknownMethod)
+	ts.Fail("SetRuntimeNTPServers", errUnknownMethod)
+
+	if err := ntp.SetLinkNTPServers(ctx, 2, []string{"192.0.2.1"}); err == nil {
+		t.Fatalf("Setting NTP servers succeeded while both services failed")
+	}
+
+	// The failed link is not merged into the servers of the next one
+	ts.Fail("SetRuntimeNTPServers", nil)
+
+	if err := ntp.SetLinkNTPServers(ctx, 3, []string{"192.0.2.3"}); err != nil {
+		t.Fatalf("Failed to set NTP servers: %v", err)
+	}
+
+	if got, want := runtimeServers(t, ts), []string{"192.0.2.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Runtime NTP servers='%v', want '%v'", got, want)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -17,26 +19,76 @@ const (
 	ConfPath = "/etc/network-broker/"
 	ConfFile = "network-broker"
 
-	DHClientLeaseFile = "/var/lib/dhclient/dhclient.leases"
 	NetworkdLeasePath = "/run/systemd/netif/leases"
-
-	ManagerStateDir   = "manager.d"
-	RoutesModifiedDir = "routes.d"
+	ResolvConfPath    = "/etc/resolv.conf"
+
+	ManagerStateDir       = "manager.d"
+	RoutesModifiedDir     = "routes.d"
+	GatewayDownDir        = "gateway-down.d"
+	GatewayUpDir          = "gateway-up.d"
+	LinkRenamedDir        = "link-renamed.d"
+	NamespaceEventsDir    = "netns.d"
+	NeighborDir           = "neighbor.d"
+	EnslavedDir           = "enslaved.d"
+	ReleasedDir           = "released.d"
+	ActiveSlaveChangedDir = "active-slave-changed.d"
+	VLANCreatedDir        = "vlan-created.d"
+	LinkChangedDir        = "link-changed.d"
+	StatisticsAlarmDir    = "stats-alarm.d"
+	AddressDADFailedDir   = "address-dadfailed.d"
+	AddressDeprecatedDir  = "address-deprecated.d"
+	AddressReadyDir       = "address-ready.d"
 
 	ROUTE_TABLE_BASE = 9999
 
+	DefaultRoutingPolicyMode = "rules"
+	DefaultMultiPathMetric   = 512
+
+	DefaultNamespacePaths = "/run/netns"
+
+	DefaultNeighborStates = "reachable stale failed"
+
+	DefaultStatisticsInterval = 10 * time.Second
+
+	DefaultTrafficControlReconcileInterval = 30 * time.Second
+
+	DefaultGatewayMonitorMethod           = "arp"
+	DefaultGatewayMonitorInterval         = 5 * time.Second
+	DefaultGatewayMonitorTimeout          = time.Second
+	DefaultGatewayMonitorFailureThreshold = 3
+	DefaultGatewayMonitorSuccessThreshold = 2
+	DefaultGatewayMonitorMetricPenalty    = 1000
+
+	DefaultDNSBackend     = "auto"
+	DefaultHostnamePolicy = "transient"
+
 	DefaultLogLevel  = "info"
 	DefaultLogFormat = "text"
 )
 
+// DHClientLeaseFile is the lease file dhclient writes, a variable so that tests can replace it.
+var DHClientLeaseFile = "/var/lib/dhclient/dhclient.leases"
+
 // Config file key value
 type Network struct {
 	Links              string `mapstructure:"Links"`
 	RoutingPolicyRules string `mapstructure:"RoutingPolicyRules"`
+	RoutingPolicyMode  string `mapstructure:"RoutingPolicyMode"`
 	UseDNS             bool   `mapstructure:"UseDNS"`
 	UseDomain          bool   `mapstructure:"UseDomain"`
 	UseHostname        bool   `mapstructure:"UseHostname"`
+	UseNTP             bool   `mapstructure:"UseNTP"`
+	HostnamePolicy     string `mapstructure:"HostnamePolicy"`
+	HostnamePriority   string `mapstructure:"HostnamePriority"`
+	HostnamePretty     bool   `mapstructure:"HostnamePretty"`
+	DNSBackend         string `mapstructure:"DNSBackend"`
+	DNSPriority        string `mapstructure:"DNSPriority"`
 	EmitJSON           bool   `mapstructure:"EmitJSON"`
+	Masquerade         string `mapstructure:"Masquerade"`
+
+	MultiPathDefaultRoute bool   `mapstructure:"MultiPathDefaultRoute"`
+	MultiPathWeights      string `mapstructure:"MultiPathWeights"`
+	MultiPathMetric       int    `mapstructure:"MultiPathMetric"`
 }
 
 type System struct {
@@ -44,21 +96,101 @@ type System struct {
 	LogLevel  string `mapstructure:"LogLevel"`
 	LogFormat string `mapstructure:"LogFormat"`
 }
+
+type GatewayMonitor struct {
+	Links            string        `mapstructure:"Links"`
+	Method           string        `mapstructure:"Method"`
+	Interval         time.Duration `mapstructure:"Interval"`
+	Timeout          time.Duration `mapstructure:"Timeout"`
+	FailureThreshold int           `mapstructure:"FailureThreshold"`
+	SuccessThreshold int           `mapstructure:"SuccessThreshold"`
+	MetricPenalty    int           `mapstructure:"MetricPenalty"`
+}
+
+type Namespace struct {
+	Names                 string `mapstructure:"Names"`
+	Paths                 string `mapstructure:"Paths"`
+	RunScriptsInNamespace bool   `mapstructure:"RunScriptsInNamespace"`
+}
+
+type Neighbor struct {
+	Links        string `mapstructure:"Links"`
+	States       string `mapstructure:"States"`
+	GatewaysOnly bool   `mapstructure:"GatewaysOnly"`
+}
+
+type Statistics struct {
+	Links      string        `mapstructure:"Links"`
+	Interval   time.Duration `mapstructure:"Interval"`
+	Thresholds string        `mapstructure:"Thresholds"`
+}
+
+// Sysctl maps link patterns to the settings applied to the matching links. The patterns are
+// read from the raw section as viper splits keys containing '.'.
+type Sysctl struct {
+	RestoreOnRemoval bool              `mapstructure:"RestoreOnRemoval"`
+	Links            map[string]string `mapstructure:"-"`
+}
+
+// TrafficControl maps link patterns to the root qdisc profiles of the matching links.
+type TrafficControl struct {
+	ReconcileInterval time.Duration     `mapstructure:"ReconcileInterval"`
+	Links             map[string]string `mapstructure:"-"`
+}
+
+// Resolve maps link patterns to the per link settings of systemd-resolved.
+type Resolve struct {
+	Links map[string]string `mapstructure:"-"`
+}
+
+// Actions maps events to the actions run on them.
+type Actions struct {
+	Events map[string]string `mapstructure:"-"`
+}
+
+// Control is the Unix socket taking actions. It is disabled when Socket= is empty.
+type Control struct {
+	Socket string `mapstructure:"Socket"`
+}
+
 type Config struct {
-	Network Network `mapstructure:"Network"`
-	System  System  `mapstructure:"System"`
+	Network        Network        `mapstructure:"Network"`
+	System         System         `mapstructure:"System"`
+	GatewayMonitor GatewayMonitor `mapstructure:"GatewayMonitor"`
+	Namespace      Namespace      `mapstructure:"Namespace"`
+	Neighbor       Neighbor       `mapstructure:"Neighbor"`
+	Statistics     Statistics     `mapstructure:"Statistics"`
+	Sysctl         Sysctl         `mapstructure:"Sysctl"`
+	TrafficControl TrafficControl `mapstructure:"TrafficControl"`
+	Resolve        Resolve        `mapstructure:"Resolve"`
+	Actions        Actions        `mapstructure:"Actions"`
+	Control        Control        `mapstructure:"Control"`
 }
 
 func createEventScriptDirs() error {
-	var eventStateDirs [7]string
-
-	eventStateDirs[0] = "no-carrier.d"
-	eventStateDirs[1] = "carrier.d"
-	eventStateDirs[2] = "degraded.d"
-	eventStateDirs[3] = "routable.d"
-	eventStateDirs[4] = "configured.d"
-	eventStateDirs[5] = ManagerStateDir
-	eventStateDirs[6] = RoutesModifiedDir
+	eventStateDirs := []string{
+		"no-carrier.d",
+		"carrier.d",
+		"degraded.d",
+		"routable.d",
+		"configured.d",
+		ManagerStateDir,
+		RoutesModifiedDir,
+		GatewayDownDir,
+		GatewayUpDir,
+		LinkRenamedDir,
+		NamespaceEventsDir,
+		NeighborDir,
+		EnslavedDir,
+		ReleasedDir,
+		ActiveSlaveChangedDir,
+		VLANCreatedDir,
+		LinkChangedDir,
+		StatisticsAlarmDir,
+		AddressDADFailedDir,
+		AddressDeprecatedDir,
+		AddressReadyDir,
+	}
 
 	for _, d := range eventStateDirs {
 		os.MkdirAll(path.Join(ConfPath, d), 0755)
@@ -107,6 +239,39 @@ func SetLogFormat(format string) error {
 	return nil
 }
 
+// parseLinkSection returns the keys of a section which are link patterns, i.e. all but
+// the given ones.
+func parseLinkSection(name string, keys ...string) map[string]string {
+	links := make(map[string]string)
+
+	m, ok := viper.Get(name).(map[string]interface{})
+	if !ok {
+		return links
+	}
+
+	for k, v := range m {
+		known := false
+		for _, key := range keys {
+			if strings.EqualFold(k, key) {
+				known = true
+			}
+		}
+		if known {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			logrus.Warnf("Ignoring %s links='%s': value must be a string", name, k)
+			continue
+		}
+
+		links[k] = s
+	}
+
+	return links
+}
+
 func Parse() (*Config, error) {
 	viper.SetConfigName(ConfFile)
 	viper.AddConfigPath(ConfPath)
@@ -118,12 +283,60 @@ func Parse() (*Config, error) {
 	viper.SetDefault("System.LogFormat", DefaultLogLevel)
 	viper.SetDefault("System.LogLevel", DefaultLogFormat)
 
+	viper.SetDefault("Network.RoutingPolicyMode", DefaultRoutingPolicyMode)
+	viper.SetDefault("Network.MultiPathMetric", DefaultMultiPathMetric)
+	viper.SetDefault("Network.DNSBackend", DefaultDNSBackend)
+	viper.SetDefault("Network.HostnamePolicy", DefaultHostnamePolicy)
+
+	viper.SetDefault("Namespace.Paths", DefaultNamespacePaths)
+	viper.SetDefault("Neighbor.States", DefaultNeighborStates)
+	viper.SetDefault("Statistics.Interval", DefaultStatisticsInterval)
+	viper.SetDefault("TrafficControl.ReconcileInterval", DefaultTrafficControlReconcileInterval)
+
+	viper.SetDefault("GatewayMonitor.Method", DefaultGatewayMonitorMethod)
+	viper.SetDefault("GatewayMonitor.Interval", DefaultGatewayMonitorInterval)
+	viper.SetDefault("GatewayMonitor.Timeout", DefaultGatewayMonitorTimeout)
+	viper.SetDefault("GatewayMonitor.FailureThreshold", DefaultGatewayMonitorFailureThreshold)
+	viper.SetDefault("GatewayMonitor.SuccessThreshold", DefaultGatewayMonitorSuccessThreshold)
+	viper.SetDefault("GatewayMonitor.MetricPenalty", DefaultGatewayMonitorMetricPenalty)
+
 	c := Config{}
 	if err := viper.Unmarshal(&c); err != nil {
 		logrus.Errorf("Failed to parse config file: '/etc/network-broker/network-broker.toml'")
 		return nil, err
 	}
 
+	// time.NewTicker panics on intervals which are not positive
+	if c.GatewayMonitor.Interval <= 0 {
+		logrus.Warnf("Invalid GatewayMonitor Interval='%v', falling back to '%v'", c.GatewayMonitor.Interval, DefaultGatewayMonitorInterval)
+		c.GatewayMonitor.Interval = DefaultGatewayMonitorInterval
+	}
+
+	if c.GatewayMonitor.Timeout <= 0 {
+		logrus.Warnf("Invalid GatewayMonitor Timeout='%v', falling back to '%v'", c.GatewayMonitor.Timeout, DefaultGatewayMonitorTimeout)
+		c.GatewayMonitor.Timeout = DefaultGatewayMonitorTimeout
+	}
+
+	if c.GatewayMonitor.FailureThreshold <= 0 {
+		logrus.Warnf("Invalid GatewayMonitor FailureThreshold='%v', falling back to '%v'", c.GatewayMonitor.FailureThreshold, DefaultGatewayMonitorFailureThreshold)
+		c.GatewayMonitor.FailureThreshold = DefaultGatewayMonitorFailureThreshold
+	}
+
+	if c.GatewayMonitor.SuccessThreshold <= 0 {
+		logrus.Warnf("Invalid GatewayMonitor SuccessThreshold='%v', falling back to '%v'", c.GatewayMonitor.SuccessThreshold, DefaultGatewayMonitorSuccessThreshold)
+		c.GatewayMonitor.SuccessThreshold = DefaultGatewayMonitorSuccessThreshold
+	}
+
+	if c.Statistics.Interval <= 0 {
+		logrus.Warnf("Invalid Statistics Interval='%v', falling back to '%v'", c.Statistics.Interval, DefaultStatisticsInterval)
+		c.Statistics.Interval = DefaultStatisticsInterval
+	}
+
+	c.Sysctl.Links = parseLinkSection("Sysctl", "RestoreOnRemoval")
+	c.TrafficControl.Links = parseLinkSection("TrafficControl", "ReconcileInterval")
+	c.Resolve.Links = parseLinkSection("Resolve")
+	c.Actions.Events = parseLinkSection("Actions")
+
 	if err := SetLogLevel(viper.GetString("NETWORK_EVENT_LOG_LEVEL")); err != nil {
 		if err := SetLogLevel(c.System.LogLevel); err != nil {
 			c.System.LogLevel = DefaultLogLevel
@@ -146,7 +359,43 @@ func Parse() (*Config, error) {
 	}
 
 	if len(c.Network.RoutingPolicyRules) > 0 {
-		logrus.Infof("Parsed RoutingPolicyRules='%+v' from configuration", c.Network.Links)
+		logrus.Infof("Parsed RoutingPolicyRules='%+v' from configuration", c.Network.RoutingPolicyRules)
+	}
+
+	if len(c.Network.DNSPriority) > 0 {
+		logrus.Infof("Parsed DNSBackend='%s' DNSPriority='%s' from configuration", c.Network.DNSBackend, c.Network.DNSPriority)
+	}
+
+	if c.Network.UseHostname {
+		logrus.Infof("Parsed HostnamePolicy='%s' HostnamePriority='%s' from configuration", c.Network.HostnamePolicy, c.Network.HostnamePriority)
+	}
+
+	if len(c.Network.Masquerade) > 0 {
+		logrus.Infof("Parsed Masquerade='%+v' from configuration", c.Network.Masquerade)
+	}
+
+	if len(c.GatewayMonitor.Links) > 0 {
+		logrus.Infof("Parsed GatewayMonitor links='%v' method='%v' from configuration", c.GatewayMonitor.Links, c.GatewayMonitor.Method)
+	}
+
+	if len(c.Namespace.Names) > 0 {
+		logrus.Infof("Parsed Namespace names='%v' paths='%v' from configuration", c.Namespace.Names, c.Namespace.Paths)
+	}
+
+	if len(c.Sysctl.Links) > 0 {
+		logrus.Infof("Parsed Sysctl='%v' from configuration", c.Sysctl.Links)
+	}
+
+	if len(c.TrafficControl.Links) > 0 {
+		logrus.Infof("Parsed TrafficControl='%v' from configuration", c.TrafficControl.Links)
+	}
+
+	if len(c.Resolve.Links) > 0 {
+		logrus.Infof("Parsed Resolve='%v' from configuration", c.Resolve.Links)
+	}
+
+	if len(c.Actions.Events) > 0 {
+		logrus.Infof("Parsed Actions='%v' from configuration", c.Actions.Events)
 	}
 
 	if err := createEventScriptDirs(); err != nil {
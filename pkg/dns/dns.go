@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const (
+	BackendAuto           = "auto"
+	BackendResolved       = "resolved"
+	BackendResolvConfFile = "resolv.conf"
+	BackendResolvconf     = "resolvconf"
+)
+
+// Backend receives the DNS servers and domains of links. Servers are given in the format
+// of resolved.conf(5) and domains prefixed with '~' are routing-only domains.
+type Backend interface {
+	Name() string
+	SetLinkDNS(index int, link string, servers []string) error
+	SetLinkDomains(index int, link string, domains []string) error
+	RevertLink(index int) error
+}
+
+// LinkMatcher matches links by name like network.LinkMatcher, which is given by the caller as
+// pkg/network imports this package.
+type LinkMatcher interface {
+	Match(name string) bool
+}
+
+// New returns the backend chosen by DNSBackend=, see Detect. The links matching earlier
+// entries of priority come first in resolv.conf.
+func New(c *conf.Config, b *bus.Clients, priority []LinkMatcher) Backend {
+	var backend Backend
+	switch Detect(c, b.Resolved) {
+	case BackendResolvConfFile:
+		backend = NewResolvConfFile(conf.ResolvConfPath, priority)
+	case BackendResolvconf:
+		backend = NewResolvconf()
+	default:
+		backend = NewResolved(b.Resolved)
+	}
+
+	log.Infof("Using DNS backend='%s'", backend.Name())
+
+	return backend
+}
+
+// Detect returns the backend chosen by DNSBackend=. With 'auto' systemd-resolved is used when
+// it is running, then resolvconf(8) when installed and /etc/resolv.conf otherwise.
+func Detect(c *conf.Config, resolved bus.Resolved) string {
+	switch name := c.Network.DNSBackend; name {
+	case BackendResolved, BackendResolvConfFile, BackendResolvconf:
+		return name
+	case "", BackendAuto:
+	default:
+		log.Warnf("Unknown DNSBackend='%s', falling back to '%s'", name, BackendResolved)
+		return BackendResolved
+	}
+
+	if resolved.Running(context.Background()) {
+		return BackendResolved
+	}
+
+	if _, err := exec.LookPath("resolvconf"); err == nil {
+		return BackendResolvconf
+	}
+
+	return BackendResolvConfFile
+}
+
+// NeedsFileAccess reports whether the backend writes files owned by root, for which
+// CAP_DAC_OVERRIDE is kept when dropping privileges.
+func NeedsFileAccess(name string) bool {
+	return name == BackendResolvConfFile || name == BackendResolvconf
+}
+
+// linkDNS holds the name servers of a link as written to resolv.conf and its search domains.
+type linkDNS struct {
+	link    string
+	servers []string
+	domains []string
+}
+
+// links keeps the settings of all links for the backends which write them out at once.
+type links struct {
+	mutex sync.Mutex
+	links map[int]*linkDNS
+}
+
+func (l *links) get(index int, link string) *linkDNS {
+	d, ok := l.links[index]
+	if !ok {
+		d = &linkDNS{}
+		l.links[index] = d
+	}
+	d.link = link
+
+	return d
+}
+
+// sorted returns the links ordered by the first matcher of priority they match, those
+// matching none last, and by ifindex.
+func (l *links) sorted(priority []LinkMatcher) []*linkDNS {
+	rank := func(link string) int {
+		for i, m := range priority {
+			if m.Match(link) {
+				return i
+			}
+		}
+
+		return len(priority)
+	}
+
+	indexes := make([]int, 0, len(l.links))
+	for index := range l.links {
+		indexes = append(indexes, index)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		ri, rj := rank(l.links[indexes[i]].link), rank(l.links[indexes[j]].link)
+		if ri != rj {
+			return ri < rj
+		}
+
+		return indexes[i] < indexes[j]
+	})
+
+	sorted := make([]*linkDNS, len(indexes))
+	for i, index := range indexes {
+		sorted[i] = l.links[index]
+	}
+
+	return sorted
+}
+
+// nameserver returns the plain address of a server as resolv.conf(5) knows neither ports nor
+// server names. IPv6 link-local addresses are scoped to the link.
+func nameserver(server string, link string) string {
+	d, err := bus.ParseDnsServer(strings.TrimSpace(server))
+	if err != nil {
+		log.Warnf("Ignoring DNS server of link='%s': %v", link, err)
+		return ""
+	}
+
+	if d.Port != 0 || d.Name != "" {
+		log.Warnf("Ignoring port and server name of DNS server='%s', not supported by resolv.conf", server)
+	}
+
+	ip := net.IP(d.Address)
+	if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		return ip.String() + "%" + link
+	}
+
+	return ip.String()
+}
+
+func nameservers(servers []string, link string) []string {
+	var addrs []string
+	for _, s := range servers {
+		addrs = appendUnique(addrs, nameserver(s, link))
+	}
+
+	return addrs
+}
+
+// searchDomains drops the routing-only domains which resolv.conf(5) cannot express.
+func searchDomains(domains []string) []string {
+	var search []string
+	for _, d := range domains {
+		if d == "" || strings.HasPrefix(d, "~") {
+			continue
+		}
+
+		search = append(search, d)
+	}
+
+	return search
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+type fakeResolved struct {
+	bus.Resolved
+
+	running bool
+}
+
+func (r *fakeResolved) Running(ctx context.Context) bool {
+	return r.running
+}
+
+// globMatcher stands in for network.LinkMatcher, which cannot be imported here.
+type globMatcher string
+
+func (g globMatcher) Match(name string) bool {
+	ok, _ := filepath.Match(string(g), name)
+	return ok
+}
+
+func linkMatchers(spec string) []LinkMatcher {
+	var m []LinkMatcher
+	for _, p := range strings.Fields(spec) {
+		m = append(m, globMatcher(p))
+	}
+
+	return m
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		backend string
+		running bool
+		want    string
+	}{
+		{BackendResolved, false, BackendResolved},
+		{BackendResolvConfFile, true, BackendResolvConfFile},
+		{BackendResolvconf, true, BackendResolvconf},
+		{BackendAuto, true, BackendResolved},
+		{"", true, BackendResolved},
+		{"dnsmasq", false, BackendResolved},
+	}
+
+	for _, tt := range tests {
+		c := &conf.Config{Network: conf.Network{DNSBackend: tt.backend}}
+
+		if got := Detect(c, &fakeResolved{running: tt.running}); got != tt.want {
+			t.Errorf("Detect('%s') with resolved running='%v'='%s', want '%s'", tt.backend, tt.running, got, tt.want)
+		}
+	}
+
+	// Without resolved one of the file backends is used, which keep CAP_DAC_OVERRIDE
+	c := &conf.Config{Network: conf.Network{DNSBackend: BackendAuto}}
+	if got := Detect(c, &fakeResolved{}); !NeedsFileAccess(got) {
+		t.Errorf("Detect without resolved='%s', want a file backend", got)
+	}
+
+	if NeedsFileAccess(BackendResolved) {
+		t.Errorf("resolved backend needs file access")
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	tests := []struct {
+		servers []string
+		want    []string
+	}{
+		{[]string{"192.0.2.1", " 192.0.2.2 "}, []string{"192.0.2.1", "192.0.2.2"}},
+		{[]string{"192.0.2.1:53", "192.0.2.1#dns.example.com"}, []string{"192.0.2.1"}},
+		{[]string{"[2001:db8::1]:853", "fe80::1"}, []string{"2001:db8::1", "fe80::1%eth0"}},
+		{[]string{"dns.example.com", ""}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := nameservers(tt.servers, "eth0"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nameservers('%v')='%v', want '%v'", tt.servers, got, tt.want)
+		}
+	}
+}
+
+func TestSearchDomains(t *testing.T) {
+	got := searchDomains([]string{"example.com", "~corp.example.com", "", "~.", "example.org"})
+	if want := []string{"example.com", "example.org"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("searchDomains='%v', want '%v'", got, want)
+	}
+}
+
+func TestLinksSorted(t *testing.T) {
+	l := &links{links: map[int]*linkDNS{
+		2: {link: "eth0"},
+		3: {link: "eth1"},
+		4: {link: "wlan0"},
+		5: {link: "eth2"},
+	}}
+
+	tests := []struct {
+		priority string
+		want     []string
+	}{
+		{"", []string{"eth0", "eth1", "wlan0", "eth2"}},
+		{"wlan*", []string{"wlan0", "eth0", "eth1", "eth2"}},
+		{"eth2 eth*", []string{"eth2", "eth0", "eth1", "wlan0"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, d := range l.sorted(linkMatchers(tt.priority)) {
+			got = append(got, d.link)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Links sorted by priority='%s'='%v', want '%v'", tt.priority, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/syndtr/gocapability/capability"
+
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+// resolvconfProtocol is appended to the link name to form the resolvconf(8) record.
+const resolvconfProtocol = ".network-broker"
+
+// Resolvconf hands the settings of each link to resolvconf(8), which orders and merges them
+// following its interface-order.
+type Resolvconf struct {
+	links
+}
+
+func NewResolvconf() *Resolvconf {
+	return &Resolvconf{
+		links: links{links: make(map[int]*linkDNS)},
+	}
+}
+
+func (r *Resolvconf) Name() string {
+	return BackendResolvconf
+}
+
+func (r *Resolvconf) SetLinkDNS(index int, link string, servers []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	d := r.get(index, link)
+	d.servers = nameservers(servers, link)
+
+	return r.add(d)
+}
+
+func (r *Resolvconf) SetLinkDomains(index int, link string, domains []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	d := r.get(index, link)
+	d.domains = searchDomains(domains)
+
+	return r.add(d)
+}
+
+func (r *Resolvconf) RevertLink(index int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	d, ok := r.links.links[index]
+	if !ok {
+		return nil
+	}
+
+	delete(r.links.links, index)
+
+	return resolvconf("", "-d", d.link+resolvconfProtocol)
+}
+
+func (r *Resolvconf) add(d *linkDNS) error {
+	var b strings.Builder
+	for _, s := range d.servers {
+		b.WriteString("nameserver " + s + "\n")
+	}
+	if len(d.domains) > 0 {
+		b.WriteString("search " + strings.Join(d.domains, " ") + "\n")
+	}
+
+	log.Debugf("Adding resolvconf record='%s' name servers='%s' search='%s'", d.link+resolvconfProtocol, strings.Join(d.servers, ","), strings.Join(d.domains, " "))
+
+	return resolvconf(b.String(), "-a", d.link+resolvconfProtocol)
+}
+
+func resolvconf(stdin string, args ...string) error {
+	// resolvconf(8) writes /run/resolvconf and /etc/resolv.conf owned by root
+	cmd := system.CapabilityCommand([]capability.Cap{capability.CAP_DAC_OVERRIDE}, "resolvconf", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("resolvconf %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
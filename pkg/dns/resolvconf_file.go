@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	resolvConfHeader = "# Generated by network-broker. Do not edit."
+
+	// glibc uses at most MAXNS name servers
+	resolvConfMaxNameservers = 3
+)
+
+// ResolvConfFile owns a resolv.conf(5) file. The servers and search domains of all links are
+// merged in the order given by DNSPriority=. The 'options' lines of the file found at the
+// first write are kept, and the file or symlink is restored when no link has settings left.
+type ResolvConfFile struct {
+	links
+
+	path     string
+	priority []LinkMatcher
+	original []byte
+	target   string
+	existed  bool
+	saved    bool
+
+	// Mode and owner of the file written, taken from the file found at the first write
+	mode os.FileMode
+	uid  int
+	gid  int
+}
+
+func NewResolvConfFile(path string, priority []LinkMatcher) *ResolvConfFile {
+	return &ResolvConfFile{
+		links:    links{links: make(map[int]*linkDNS)},
+		path:     path,
+		priority: priority,
+		mode:     0644,
+	}
+}
+
+func (r *ResolvConfFile) Name() string {
+	return BackendResolvConfFile
+}
+
+func (r *ResolvConfFile) SetLinkDNS(index int, link string, servers []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.get(index, link).servers = nameservers(servers, link)
+
+	return r.write()
+}
+
+func (r *ResolvConfFile) SetLinkDomains(index int, link string, domains []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.get(index, link).domains = searchDomains(domains)
+
+	return r.write()
+}
+
+func (r *ResolvConfFile) RevertLink(index int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.links.links[index]; !ok {
+		return nil
+	}
+
+	delete(r.links.links, index)
+
+	return r.write()
+}
+
+func (r *ResolvConfFile) write() error {
+	if !r.saved {
+		b, err := os.ReadFile(r.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read '%s': %w", r.path, err)
+		}
+
+		// Do not take over our own file left behind by an earlier run
+		if !strings.HasPrefix(string(b), resolvConfHeader) {
+			r.original = b
+			r.existed = err == nil
+			r.target, _ = os.Readlink(r.path)
+		}
+
+		// A symlink or a missing file is replaced by one owned by root
+		if fi, err := os.Lstat(r.path); err == nil && fi.Mode().IsRegular() {
+			if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+				r.mode, r.uid, r.gid = fi.Mode().Perm(), int(st.Uid), int(st.Gid)
+			}
+		}
+		r.saved = true
+	}
+
+	if len(r.links.links) == 0 {
+		return r.restore()
+	}
+
+	var nameservers, search []string
+	for _, l := range r.sorted(r.priority) {
+		nameservers = appendUnique(nameservers, l.servers...)
+		search = appendUnique(search, l.domains...)
+	}
+
+	if len(nameservers) > resolvConfMaxNameservers {
+		log.Debugf("Writing only the first %d of name servers='%s' to '%s'", resolvConfMaxNameservers, strings.Join(nameservers, ","), r.path)
+		nameservers = nameservers[:resolvConfMaxNameservers]
+	}
+
+	var b strings.Builder
+	b.WriteString(resolvConfHeader + "\n")
+	for _, s := range nameservers {
+		b.WriteString("nameserver " + s + "\n")
+	}
+	if len(search) > 0 {
+		b.WriteString("search " + strings.Join(search, " ") + "\n")
+	}
+	for _, line := range strings.Split(string(r.original), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "options") {
+			b.WriteString(strings.TrimSpace(line) + "\n")
+		}
+	}
+
+	log.Debugf("Writing name servers='%s' search='%s' to '%s'", strings.Join(nameservers, ","), strings.Join(search, " "), r.path)
+
+	return r.replace([]byte(b.String()))
+}
+
+func (r *ResolvConfFile) restore() error {
+	log.Debugf("Restoring '%s'", r.path)
+
+	switch {
+	case r.target != "":
+		tmp := filepath.Join(filepath.Dir(r.path), "."+filepath.Base(r.path)+".network-broker")
+		os.Remove(tmp)
+
+		if err := os.Symlink(r.target, tmp); err != nil {
+			return fmt.Errorf("failed to restore '%s': %w", r.path, err)
+		}
+
+		if err := os.Rename(tmp, r.path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to restore '%s': %w", r.path, err)
+		}
+
+		return nil
+	case !r.existed:
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove '%s': %w", r.path, err)
+		}
+
+		return nil
+	}
+
+	return r.replace(r.original)
+}
+
+// replace writes the file atomically with the mode and owner of the original. A symlink, e.g.
+// to the stub file of systemd-resolved, is replaced by the file itself.
+func (r *ResolvConfFile) replace(b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(r.path), "."+filepath.Base(r.path))
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %w", r.path, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write '%s': %w", r.path, err)
+	}
+
+	// The temporary file is owned by the user network-broker runs as
+	if err := f.Chmod(r.mode); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write '%s': %w", r.path, err)
+	}
+
+	if err := f.Chown(r.uid, r.gid); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to change owner of '%s': %w", r.path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", r.path, err)
+	}
+
+	if err := os.Rename(f.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", r.path, err)
+	}
+
+	return nil
+}
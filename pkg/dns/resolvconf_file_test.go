@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func statResolvConf(t *testing.T, path string) (os.FileMode, uint32, uint32) {
+	t.Helper()
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat '%s': %v", path, err)
+	}
+
+	st := fi.Sys().(*syscall.Stat_t)
+
+	return fi.Mode(), st.Uid, st.Gid
+}
+
+func skipUnlessRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing the owner of files needs CAP_CHOWN")
+	}
+}
+
+func readResolvConf(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read '%s': %v", path, err)
+	}
+
+	return string(b)
+}
+
+func TestResolvConfFileMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	original := "# written by hand\nnameserver 198.51.100.1\noptions edns0\n  options rotate\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write '%s': %v", path, err)
+	}
+
+	r := NewResolvConfFile(path, linkMatchers("wlan* eth1"))
+
+	steps := []struct {
+		name string
+		set  func() error
+		want string
+	}{
+		{
+			"first link",
+			func() error { return r.SetLinkDNS(2, "eth0", []string{"192.0.2.1", "192.0.2.1:53#dns.example.com"}) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 192.0.2.1\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+		{
+			"links of DNSPriority= first",
+			func() error { return r.SetLinkDNS(3, "eth1", []string{"192.0.2.2", "fe80::1"}) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 192.0.2.2\n" +
+				"nameserver fe80::1%eth1\n" +
+				"nameserver 192.0.2.1\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+		{
+			"at most three name servers",
+			func() error { return r.SetLinkDNS(4, "wlan0", []string{"2001:db8::53"}) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 2001:db8::53\n" +
+				"nameserver 192.0.2.2\n" +
+				"nameserver fe80::1%eth1\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+		{
+			"routing-only domains dropped",
+			func() error { return r.SetLinkDomains(2, "eth0", []string{"example.com", "~corp.example.com"}) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 2001:db8::53\n" +
+				"nameserver 192.0.2.2\n" +
+				"nameserver fe80::1%eth1\n" +
+				"search example.com\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+		{
+			"search domains merged",
+			func() error { return r.SetLinkDomains(3, "eth1", []string{"example.org", "example.com"}) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 2001:db8::53\n" +
+				"nameserver 192.0.2.2\n" +
+				"nameserver fe80::1%eth1\n" +
+				"search example.org example.com\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+		{
+			"reverted link dropped",
+			func() error { return r.RevertLink(4) },
+			"# Generated by network-broker. Do not edit.\n" +
+				"nameserver 192.0.2.2\n" +
+				"nameserver fe80::1%eth1\n" +
+				"nameserver 192.0.2.1\n" +
+				"search example.org example.com\n" +
+				"options edns0\n" +
+				"options rotate\n",
+		},
+	}
+
+	for _, s := range steps {
+		if err := s.set(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+
+		if got := readResolvConf(t, path); got != s.want {
+			t.Fatalf("%s: resolv.conf=\n%s\nwant\n%s", s.name, got, s.want)
+		}
+	}
+
+	if err := r.RevertLink(5); err != nil {
+		t.Fatalf("Reverting an unknown link failed: %v", err)
+	}
+
+	for _, index := range []int{2, 3} {
+		if err := r.RevertLink(index); err != nil {
+			t.Fatalf("Failed to revert ifindex='%d': %v", index, err)
+		}
+	}
+
+	if got := readResolvConf(t, path); got != original {
+		t.Fatalf("resolv.conf not restored=\n%s\nwant\n%s", got, original)
+	}
+}
+
+func TestResolvConfFileKeepsModeAndOwner(t *testing.T) {
+	skipUnlessRoot(t)
+
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("nameserver 198.51.100.1\n"), 0640); err != nil {
+		t.Fatalf("Failed to write '%s': %v", path, err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatalf("Failed to change mode of '%s': %v", path, err)
+	}
+	if err := os.Chown(path, 1234, 5678); err != nil {
+		t.Fatalf("Failed to change owner of '%s': %v", path, err)
+	}
+
+	r := NewResolvConfFile(path, nil)
+	if err := r.SetLinkDNS(2, "eth0", []string{"192.0.2.1"}); err != nil {
+		t.Fatalf("Failed to set DNS: %v", err)
+	}
+
+	if mode, uid, gid := statResolvConf(t, path); mode != 0640 || uid != 1234 || gid != 5678 {
+		t.Fatalf("'%s' mode='%v' owner='%d:%d', want '-rw-r-----' '1234:5678'", path, mode, uid, gid)
+	}
+
+	if err := r.RevertLink(2); err != nil {
+		t.Fatalf("Failed to revert: %v", err)
+	}
+
+	if mode, uid, gid := statResolvConf(t, path); mode != 0640 || uid != 1234 || gid != 5678 {
+		t.Fatalf("Restored '%s' mode='%v' owner='%d:%d', want '-rw-r-----' '1234:5678'", path, mode, uid, gid)
+	}
+}
+
+func TestResolvConfFileRestoreSymlink(t *testing.T) {
+	skipUnlessRoot(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	target := filepath.Join(dir, "stub-resolv.conf")
+
+	if err := os.WriteFile(target, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatalf("Failed to write '%s': %v", target, err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("Failed to link '%s': %v", path, err)
+	}
+
+	r := NewResolvConfFile(path, nil)
+	if err := r.SetLinkDNS(2, "eth0", []string{"192.0.2.1"}); err != nil {
+		t.Fatalf("Failed to set DNS: %v", err)
+	}
+
+	if mode, uid, gid := statResolvConf(t, path); mode != 0644 || uid != 0 || gid != 0 {
+		t.Fatalf("'%s' mode='%v' owner='%d:%d', want a file '-rw-r--r--' of root", path, mode, uid, gid)
+	}
+
+	if got := readResolvConf(t, target); got != "nameserver 127.0.0.53\n" {
+		t.Fatalf("Symlink target written: %s", got)
+	}
+
+	if err := r.RevertLink(2); err != nil {
+		t.Fatalf("Failed to revert: %v", err)
+	}
+
+	if got, err := os.Readlink(path); err != nil || got != target {
+		t.Fatalf("Symlink restored to '%s' err='%v', want '%s'", got, err, target)
+	}
+}
+
+func TestResolvConfFileRestoreMissing(t *testing.T) {
+	skipUnlessRoot(t)
+
+	for _, tt := range []struct {
+		name     string
+		leftover string
+	}{
+		{"no file", ""},
+		{"file of an earlier run", resolvConfHeader + "\nnameserver 192.0.2.9\n"},
+	} {
+		path := filepath.Join(t.TempDir(), "resolv.conf")
+		if tt.leftover != "" {
+			if err := os.WriteFile(path, []byte(tt.leftover), 0644); err != nil {
+				t.Fatalf("Failed to write '%s': %v", path, err)
+			}
+		}
+
+		r := NewResolvConfFile(path, nil)
+		if err := r.SetLinkDNS(2, "eth0", []string{"192.0.2.1"}); err != nil {
+			t.Fatalf("%s: failed to set DNS: %v", tt.name, err)
+		}
+
+		if err := r.RevertLink(2); err != nil {
+			t.Fatalf("%s: failed to revert: %v", tt.name, err)
+		}
+
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s: '%s' left behind: %v", tt.name, path, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package dns
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+)
+
+// Resolved pushes the settings of each link to systemd-resolved over D-Bus.
+type Resolved struct {
+	client bus.Resolved
+}
+
+func NewResolved(client bus.Resolved) *Resolved {
+	return &Resolved{client: client}
+}
+
+func (r *Resolved) Name() string {
+	return BackendResolved
+}
+
+// SetLinkDNS sets DNS servers of both families. Servers with a port or server name need
+// SetLinkDNSEx, older resolved only knows SetLinkDNS.
+func (r *Resolved) SetLinkDNS(index int, link string, servers []string) error {
+	var linkDnsEx []bus.DnsServerEx
+	for _, s := range servers {
+		d, err := bus.ParseDnsServer(strings.TrimSpace(s))
+		if err != nil {
+			log.Warnf("Ignoring DNS server link='%s' ifindex='%d': %v", link, index, err)
+			continue
+		}
+
+		linkDnsEx = append(linkDnsEx, *d)
+	}
+
+	if len(linkDnsEx) == 0 {
+		return nil
+	}
+
+	err := r.client.SetLinkDNSEx(context.Background(), index, linkDnsEx)
+	if err == nil {
+		return nil
+	}
+
+	log.Debugf("Falling back to SetLinkDNS link='%s' ifindex='%d': %v", link, index, err)
+
+	linkDns := make([]bus.DnsServer, len(linkDnsEx))
+	for i, d := range linkDnsEx {
+		if d.Port != 0 || d.Name != "" {
+			log.Warnf("Ignoring port and server name of DNS server link='%s' ifindex='%d', not supported by systemd-resolved", link, index)
+		}
+
+		linkDns[i] = bus.DnsServer{
+			Family:  d.Family,
+			Address: d.Address,
+		}
+	}
+
+	return r.client.SetLinkDNS(context.Background(), index, linkDns)
+}
+
+// SetLinkDomains sets search domains and, prefixed with '~', routing-only domains.
+func (r *Resolved) SetLinkDomains(index int, link string, domains []string) error {
+	linkDomains := make([]bus.Domain, len(domains))
+	for i, domain := range domains {
+		linkDomains[i] = bus.Domain{
+			Domain:      strings.TrimPrefix(domain, "~"),
+			RoutingOnly: strings.HasPrefix(domain, "~"),
+		}
+	}
+
+	return r.client.SetLinkDomains(context.Background(), index, linkDomains)
+}
+
+func (r *Resolved) RevertLink(index int) error {
+	return r.client.RevertLink(context.Background(), index)
+}
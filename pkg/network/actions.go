@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	ActionReload      = "reload"
+	ActionReconfigure = "reconfigure"
+	ActionRenew       = "renew"
+	ActionForceRenew  = "force-renew"
+	ActionDNS         = "dns"
+	ActionRevertDNS   = "revert-dns"
+	ActionUp          = "up"
+	ActionDown        = "down"
+
+	// actionEventLink stands for the link of the event triggering the action
+	actionEventLink = "$LINK"
+)
+
+// Action is a control operation of systemd-networkd written as 'operation[:link][=args]',
+// e.g. 'renew:eth1' or 'dns:eth1=1.1.1.1,2606:4700::1111'.
+type Action struct {
+	Operation string
+	Link      string
+	Args      []string
+}
+
+func ParseAction(s string) (*Action, error) {
+	op, rest, _ := strings.Cut(strings.TrimSpace(s), ":")
+	link, args, hasArgs := strings.Cut(rest, "=")
+
+	a := &Action{
+		Operation: op,
+		Link:      link,
+	}
+	if hasArgs {
+		a.Args = strings.Split(args, ",")
+	}
+
+	switch a.Operation {
+	case ActionReload:
+		if a.Link != "" {
+			return nil, fmt.Errorf("action '%s' takes no link", s)
+		}
+	case ActionReconfigure, ActionRenew, ActionForceRenew, ActionRevertDNS, ActionUp, ActionDown:
+		if a.Link == "" {
+			return nil, fmt.Errorf("action '%s' needs a link", s)
+		}
+	case ActionDNS:
+		if a.Link == "" || len(a.Args) == 0 {
+			return nil, fmt.Errorf("action '%s' needs a link and DNS servers", s)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action '%s'", s)
+	}
+
+	return a, nil
+}
+
+func (a *Action) String() string {
+	s := a.Operation
+	if a.Link != "" {
+		s += ":" + a.Link
+	}
+	if len(a.Args) > 0 {
+		s += "=" + strings.Join(a.Args, ",")
+	}
+
+	return s
+}
+
+// RunAction runs an action. An action on '$LINK' runs on eventLink.
+func (n *Network) RunAction(ctx context.Context, a *Action, eventLink string) error {
+	link := a.Link
+	if link == actionEventLink {
+		if eventLink == "" {
+			return fmt.Errorf("action '%s' without the link of an event", a)
+		}
+
+		link = eventLink
+	}
+
+	index := 0
+	if link != "" {
+		n.Mutex.Lock()
+		i, ok := n.LinksByName[link]
+		n.Mutex.Unlock()
+		if !ok {
+			return fmt.Errorf("action '%s': link='%s' not found", a, link)
+		}
+
+		index = i
+	}
+
+	log.Infof("Running action='%s' link='%s' ifindex='%d'", a.Operation, link, index)
+
+	networkd := n.Bus.Networkd
+
+	switch a.Operation {
+	case ActionReload:
+		return networkd.Reload(ctx)
+	case ActionReconfigure:
+		return networkd.ReconfigureLink(ctx, index)
+	case ActionRenew:
+		return networkd.RenewLink(ctx, index)
+	case ActionForceRenew:
+		return networkd.ForceRenewLink(ctx, index)
+	case ActionDNS:
+		var dns []bus.DnsServer
+		for _, s := range a.Args {
+			d, err := bus.ParseDnsServer(s)
+			if err != nil {
+				return err
+			}
+
+			dns = append(dns, bus.DnsServer{
+				Family:  d.Family,
+				Address: d.Address,
+			})
+		}
+
+		return networkd.SetLinkDNS(ctx, index, dns)
+	case ActionRevertDNS:
+		return networkd.RevertLinkDNS(ctx, index)
+	case ActionUp, ActionDown:
+		// systemd-networkd has no method for these, 'networkctl up' uses netlink as well
+		l, err := netlink.LinkByIndex(index)
+		if err != nil {
+			return err
+		}
+
+		if a.Operation == ActionUp {
+			return netlink.LinkSetUp(l)
+		}
+
+		return netlink.LinkSetDown(l)
+	}
+
+	return fmt.Errorf("unknown action '%s'", a)
+}
+
+// Actions maps events, named after their script dir without '.d', to the actions run on them.
+type Actions struct {
+	events map[string][]*Action
+}
+
+func NewActions(c *conf.Config) *Actions {
+	a := &Actions{
+		events: make(map[string][]*Action),
+	}
+
+	events := make([]string, 0, len(c.Actions.Events))
+	for e := range c.Actions.Events {
+		events = append(events, e)
+	}
+	sort.Strings(events)
+
+	for _, e := range events {
+		for _, s := range strings.Fields(c.Actions.Events[e]) {
+			action, err := ParseAction(s)
+			if err != nil {
+				log.Warnf("Ignoring action of event='%s': %v", e, err)
+				continue
+			}
+
+			// The links of namespace events live in other network namespaces than the actions run in
+			if e == strings.TrimSuffix(conf.NamespaceEventsDir, ".d") && action.Link == actionEventLink {
+				log.Warnf("Ignoring action='%s' of event='%s': '%s' is a link of another network namespace", action, e, actionEventLink)
+				continue
+			}
+
+			a.events[e] = append(a.events[e], action)
+		}
+	}
+
+	return a
+}
+
+// WatchActions runs the actions of each event after its scripts were looked up.
+func WatchActions(n *Network, c *conf.Config) {
+	a := NewActions(c)
+	if len(a.events) == 0 {
+		return
+	}
+
+	system.AddEventHook(func(dir string, env []string) {
+		actions := a.events[strings.TrimSuffix(dir, ".d")]
+		if len(actions) == 0 {
+			return
+		}
+
+		link := ""
+		for _, e := range env {
+			if v, ok := strings.CutPrefix(e, "LINK="); ok {
+				link = v
+			}
+		}
+
+		// Do not hold up the watcher reporting the event
+		go func() {
+			for _, action := range actions {
+				if err := n.RunAction(context.Background(), action, link); err != nil {
+					log.Warnf("Failed to run action='%s' on event='%s': %v", action, dir, err)
+				}
+			}
+		}()
+	})
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *Action
+	}{
+		{"reload", &Action{Operation: ActionReload}},
+		{" renew:eth1 ", &Action{Operation: ActionRenew, Link: "eth1"}},
+		{"force-renew:eth1", &Action{Operation: ActionForceRenew, Link: "eth1"}},
+		{"reconfigure:$LINK", &Action{Operation: ActionReconfigure, Link: "$LINK"}},
+		{"revert-dns:eth1", &Action{Operation: ActionRevertDNS, Link: "eth1"}},
+		{"up:eth1", &Action{Operation: ActionUp, Link: "eth1"}},
+		{"down:eth1", &Action{Operation: ActionDown, Link: "eth1"}},
+		{"dns:eth1=1.1.1.1", &Action{Operation: ActionDNS, Link: "eth1", Args: []string{"1.1.1.1"}}},
+		{"dns:eth1=1.1.1.1,2606:4700::1111", &Action{Operation: ActionDNS, Link: "eth1", Args: []string{"1.1.1.1", "2606:4700::1111"}}},
+
+		{"", nil},
+		{"restart", nil},
+		{"reload:eth1", nil},
+		{"renew", nil},
+		{"renew:", nil},
+		{"dns:eth1", nil},
+		{"dns=1.1.1.1", nil},
+		{"Renew:eth1", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAction(tt.s)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseAction('%s')=%+v, want error", tt.s, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseAction('%s') failed: %v", tt.s, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAction('%s')=%+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestActionString(t *testing.T) {
+	for _, s := range []string{"reload", "renew:eth1", "dns:eth1=1.1.1.1,2606:4700::1111"} {
+		a, err := ParseAction(s)
+		if err != nil {
+			t.Fatalf("ParseAction('%s') failed: %v", s, err)
+		}
+
+		if got := a.String(); got != s {
+			t.Errorf("Action of '%s' String()='%s'", s, got)
+		}
+	}
+}
+
+func TestNewActions(t *testing.T) {
+	c := &conf.Config{
+		Actions: conf.Actions{
+			Events: map[string]string{
+				"gateway-down": "renew:eth1 reconfigure:$LINK bogus",
+				"netns":        "reconfigure:$LINK renew:eth1",
+			},
+		},
+	}
+
+	got := make(map[string][]string)
+	for e, actions := range NewActions(c).events {
+		for _, a := range actions {
+			got[e] = append(got[e], a.String())
+		}
+	}
+
+	want := map[string][]string{
+		"gateway-down": {"renew:eth1", "reconfigure:$LINK"},
+		"netns":        {"renew:eth1"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Actions='%v', want '%v'", got, want)
+	}
+}
@@ -4,9 +4,56 @@
 package network
 
 import (
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/parser"
+	"github.com/vmware/network-event-broker/pkg/system"
 )
 
+var addressFlagNames = []struct {
+	flag int
+	name string
+}{
+	{unix.IFA_F_SECONDARY, "secondary"},
+	{unix.IFA_F_NODAD, "nodad"},
+	{unix.IFA_F_OPTIMISTIC, "optimistic"},
+	{unix.IFA_F_DADFAILED, "dadfailed"},
+	{unix.IFA_F_HOMEADDRESS, "homeaddress"},
+	{unix.IFA_F_DEPRECATED, "deprecated"},
+	{unix.IFA_F_TENTATIVE, "tentative"},
+	{unix.IFA_F_PERMANENT, "permanent"},
+	{unix.IFA_F_MANAGETEMPADDR, "mngtmpaddr"},
+	{unix.IFA_F_NOPREFIXROUTE, "noprefixroute"},
+	{unix.IFA_F_MCAUTOJOIN, "autojoin"},
+	{unix.IFA_F_STABLE_PRIVACY, "stable-privacy"},
+}
+
+func addressFlags(flags int) string {
+	var names []string
+	for _, f := range addressFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// isAddressReady reports whether an address has passed duplicate address detection.
+func isAddressReady(flags int) bool {
+	return flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) == 0
+}
+
+func addressKey(index int, address string) string {
+	return strconv.Itoa(index) + "/" + address
+}
+
 func getIPv4AddressesByLink(name string) (map[string]bool, error) {
 	link, err := netlink.LinkByName(name)
 	if err != nil {
@@ -20,8 +67,87 @@ func getIPv4AddressesByLink(name string) (map[string]bool, error) {
 
 	m := make(map[string]bool)
 	for _, addr := range addresses {
+		if !isAddressReady(addr.Flags) {
+			continue
+		}
+
 		m[addr.IPNet.String()] = true
 	}
 
 	return m, nil
 }
+
+// acquireAddressFlags records the flags of the addresses present at startup so that their
+// later updates are not mistaken for new addresses.
+func (n *Network) acquireAddressFlags(link netlink.Link) {
+	addresses, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		log.Debugf("Failed to acquire addresses of link='%s': %v", link.Attrs().Name, err)
+		return
+	}
+
+	for _, addr := range addresses {
+		n.AddressFlagsByKey[addressKey(link.Attrs().Index, addr.IPNet.String())] = addr.Flags
+	}
+}
+
+// dropAddressFlags forgets the addresses of a removed link. Callers must hold n.Mutex.
+func (n *Network) dropAddressFlags(index int) {
+	prefix := strconv.Itoa(index) + "/"
+	for k := range n.AddressFlagsByKey {
+		if strings.HasPrefix(k, prefix) {
+			delete(n.AddressFlagsByKey, k)
+		}
+	}
+}
+
+// updateAddressFlags compares the flags of an added or updated address with the previous
+// update, executes the address scripts on transitions and reports whether the address has
+// just become ready.
+func (n *Network) updateAddressFlags(update netlink.AddrUpdate) bool {
+	address := update.LinkAddress.String()
+	key := addressKey(update.LinkIndex, address)
+
+	n.Mutex.Lock()
+	old, known := n.AddressFlagsByKey[key]
+	n.AddressFlagsByKey[key] = update.Flags
+	link := n.LinksByIndex[update.LinkIndex]
+	n.Mutex.Unlock()
+
+	set := func(flag int) bool {
+		return update.Flags&flag != 0 && (!known || old&flag == 0)
+	}
+
+	if set(unix.IFA_F_DADFAILED) {
+		log.Warnf("Duplicate address detection failed for address='%s' on link='%s' ifindex='%d'", address, link, update.LinkIndex)
+
+		executeAddressScripts(conf.AddressDADFailedDir, link, update)
+	}
+
+	if set(unix.IFA_F_DEPRECATED) {
+		log.Infof("Address='%s' on link='%s' ifindex='%d' deprecated", address, link, update.LinkIndex)
+
+		executeAddressScripts(conf.AddressDeprecatedDir, link, update)
+	}
+
+	ready := isAddressReady(update.Flags) && (!known || !isAddressReady(old))
+	if ready {
+		log.Infof("Address='%s' on link='%s' ifindex='%d' ready", address, link, update.LinkIndex)
+
+		executeAddressScripts(conf.AddressReadyDir, link, update)
+	}
+
+	return ready
+}
+
+func executeAddressScripts(dir string, link string, update netlink.AddrUpdate) {
+	system.ExecuteScriptsInDir(dir,
+		"LINK="+link,
+		"LINKINDEX="+strconv.Itoa(update.LinkIndex),
+		"ADDRESS="+update.LinkAddress.String(),
+		"FAMILY="+parser.IP4or6(update.LinkAddress.IP.String()),
+		"FLAGS="+addressFlags(update.Flags),
+		"PREFERRED_LFT="+strconv.Itoa(update.PreferedLft),
+		"VALID_LFT="+strconv.Itoa(update.ValidLft),
+	)
+}
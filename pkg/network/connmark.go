@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const (
+	RoutingPolicyModeFwMark = "fwmark"
+
+	nftLinkMarksMap = "link_marks"
+)
+
+// setupConnmark creates the chains which connmark flows entering the managed links and
+// restore the mark on replies. Links are added to the map 'link_marks' one by one.
+func setupConnmark() error {
+	return nftApply(
+		"add table "+NftTableFamily+" "+NftTableName,
+		nftObject("add map", nftLinkMarksMap)+" { type ifname : mark; }",
+		nftObject("add chain", "prerouting")+" { type filter hook prerouting priority mangle; policy accept; }",
+		nftObject("add chain", "output")+" { type route hook output priority mangle; policy accept; }",
+		nftObject("flush chain", "prerouting"),
+		nftObject("flush chain", "output"),
+		nftObject("add rule", "prerouting")+" ct state new ct mark set iifname map @"+nftLinkMarksMap,
+		nftObject("add rule", "prerouting")+" ct direction reply ct mark != 0 meta mark set ct mark",
+		nftObject("add rule", "output")+" ct direction reply ct mark != 0 meta mark set ct mark",
+	)
+}
+
+// configureConnmark marks connections entering the link and routes replies carrying the mark
+// via the table of the link. Callers must hold n.Mutex.
+func (n *Network) configureConnmark(link string, index int) error {
+	table := conf.ROUTE_TABLE_BASE + index
+
+	if !n.connmarkReady {
+		if err := setupConnmark(); err != nil {
+			log.Warnf("Failed to setup nftables connmark rules: %v", err)
+			return err
+		}
+
+		n.connmarkReady = true
+	}
+
+	// A renamed link is found in the map under its old name
+	if old, ok := n.connmarkLinks[index]; ok && old != link {
+		n.deleteConnmarkElement(old, index)
+	}
+
+	if err := nftApply(nftObject("add element", nftLinkMarksMap) + " { \"" + link + "\" : " + strconv.Itoa(table) + " }"); err != nil {
+		log.Warnf("Failed to add connmark for link='%s' ifindex='%d': %v", link, index, err)
+		return err
+	}
+	n.connmarkLinks[index] = link
+
+	rule := &RoutingRule{
+		Mark:  table,
+		Table: table,
+	}
+
+	if err := rule.RoutingPolicyRuleAdd(); err != nil {
+		log.Warnf("Failed to add fwmark routing policy rule for link='%s' ifindex='%d': %v", link, index, err)
+		return err
+	}
+
+	n.RoutingRulesByMark[index] = rule
+
+	log.Debugf("Successfully added fwmark routing policy rule on link='%s' ifindex='%d' mark='%d' table='%d'", link, index, table, table)
+
+	return nil
+}
+
+// dropConnmark removes the connmark of the link and its fwmark rule. Callers must hold n.Mutex.
+func (n *Network) dropConnmark(index int) {
+	if rule, ok := n.RoutingRulesByMark[index]; ok {
+		delete(n.RoutingRulesByMark, index)
+
+		rule.RoutingPolicyRuleRemove()
+	}
+
+	if link, ok := n.connmarkLinks[index]; ok {
+		n.deleteConnmarkElement(link, index)
+	}
+}
+
+// deleteConnmarkElement removes the link from the map 'link_marks' by the name it was added
+// under. Callers must hold n.Mutex.
+func (n *Network) deleteConnmarkElement(link string, index int) {
+	delete(n.connmarkLinks, index)
+
+	if err := nftApply(nftObject("delete element", nftLinkMarksMap) + " { \"" + link + "\" }"); err != nil {
+		log.Debugf("Failed to remove connmark of link='%s' ifindex='%d': %v", link, index, err)
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+// WatchControl takes one action per line on the control socket and answers each with 'OK' or
+// 'ERROR' followed by the reason.
+func WatchControl(n *Network, c *conf.Config) {
+	socket := c.Control.Socket
+
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Failed to remove stale control socket='%s': %v", socket, err)
+		return
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Errorf("Failed to listen on control socket='%s': %v", socket, err)
+		return
+	}
+	defer l.Close()
+
+	if err := os.Chmod(socket, 0660); err != nil {
+		log.Warnf("Failed to set mode of control socket='%s': %v", socket, err)
+	}
+
+	log.Infof("Listening on control socket='%s'", socket)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Errorf("Failed to accept on control socket='%s': %v", socket, err)
+			return
+		}
+
+		go serveControl(n, conn)
+	}
+}
+
+func serveControl(n *Network, conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		reply := "OK"
+		if err := controlAction(n, line); err != nil {
+			reply = "ERROR " + err.Error()
+		}
+
+		if _, err := fmt.Fprintln(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func controlAction(n *Network, line string) error {
+	a, err := ParseAction(line)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Received action='%s' on control socket", a)
+
+	ctx, cancel := context.WithTimeout(context.Background(), bus.DefaultTimeout)
+	defer cancel()
+
+	return n.RunAction(ctx, a, "")
+}
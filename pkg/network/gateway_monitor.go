@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+// GatewayState is the health of the gateway of a monitored link. IfIndex never changes, the
+// other fields are guarded by n.Mutex.
+type GatewayState struct {
+	IfIndex int
+	Link    string
+	Gw      string
+	Healthy bool
+
+	failures  int
+	successes int
+
+	// Default routes of the main table which were replaced with a worse metric
+	demoted []demotedRoute
+}
+
+const (
+	gatewayUnchanged = iota
+	gatewayWentDown
+	gatewayCameUp
+)
+
+// observe records whether gw answered a probe and reports whether the gateway went down or
+// came up after the thresholds of consecutive failures or successes. A new gateway starts
+// counting afresh. Callers must hold n.Mutex.
+func (gs *GatewayState) observe(gw string, answered bool, c *conf.GatewayMonitor) int {
+	if gs.Gw != gw {
+		gs.Gw = gw
+		gs.failures = 0
+		gs.successes = 0
+	}
+
+	if answered {
+		gs.failures = 0
+		gs.successes++
+	} else {
+		gs.successes = 0
+		gs.failures++
+	}
+
+	switch {
+	case gs.Healthy && gs.failures >= c.FailureThreshold:
+		gs.Healthy = false
+		return gatewayWentDown
+	case !gs.Healthy && gs.successes >= c.SuccessThreshold:
+		gs.Healthy = true
+		return gatewayCameUp
+	}
+
+	return gatewayUnchanged
+}
+
+type demotedRoute struct {
+	original netlink.Route
+	demoted  netlink.Route
+}
+
+func WatchGateways(n *Network, c *conf.Config) {
+	prober, err := NewProber(c.GatewayMonitor.Method)
+	if err != nil {
+		log.Errorf("Failed to start gateway monitor method='%s': %v", c.GatewayMonitor.Method, err)
+		return
+	}
+
+	log.Infof("Starting gateway monitor for links='%s' method='%s'", c.GatewayMonitor.Links, c.GatewayMonitor.Method)
+
+	for _, link := range strings.Fields(c.GatewayMonitor.Links) {
+		go n.monitorGateway(link, prober, &c.GatewayMonitor)
+	}
+}
+
+func (n *Network) gatewayState(link string) (*GatewayState, bool) {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+
+	index, ok := n.LinksByName[link]
+	if !ok {
+		return nil, false
+	}
+
+	gs, ok := n.GatewaysByIndex[index]
+	if !ok {
+		gs = &GatewayState{
+			IfIndex: index,
+			Link:    link,
+			Healthy: true,
+		}
+		n.GatewaysByIndex[index] = gs
+	}
+
+	return gs, true
+}
+
+// IsGatewayHealthy reports whether the gateway of a link answers probes. Links which
+// are not monitored are always considered healthy. Callers must hold n.Mutex.
+func (n *Network) IsGatewayHealthy(index int) bool {
+	gs, ok := n.GatewaysByIndex[index]
+	if !ok {
+		return true
+	}
+
+	return gs.Healthy
+}
+
+func lookupGateway(index int) (net.IP, error) {
+	gw, err := GetDefaultIpv4GatewayByLink(index)
+	if err != nil {
+		gw, err = GetIpv4GatewayByLink(index)
+		if err != nil {
+			gw, err = GetIpv6GatewayByLink(index)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ip := net.ParseIP(gw)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid gateway='%s' on ifindex='%d'", gw, index)
+	}
+
+	return ip, nil
+}
+
+func (n *Network) monitorGateway(link string, prober Prober, c *conf.GatewayMonitor) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		gs, ok := n.gatewayState(link)
+		if !ok {
+			log.Debugf("Gateway monitor: link='%s' not found", link)
+			continue
+		}
+
+		gw, err := lookupGateway(gs.IfIndex)
+		if err != nil {
+			log.Debugf("Gateway monitor: no gateway on link='%s' ifindex='%d'", link, gs.IfIndex)
+			continue
+		}
+
+		// The probe takes up to the timeout, so it runs without the lock
+		err = prober.Probe(gs.IfIndex, gw, c.Timeout)
+		if err != nil {
+			log.Debugf("Gateway monitor: gateway='%s' on link='%s' did not answer: %v", gw, link, err)
+		}
+
+		n.Mutex.Lock()
+		if gs.Gw != gw.String() {
+			log.Debugf("Gateway monitor: gateway='%s' on link='%s' ifindex='%d'", gw, gs.Link, gs.IfIndex)
+		}
+		transition := gs.observe(gw.String(), err == nil, c)
+		name := gs.Link
+		n.Mutex.Unlock()
+
+		switch transition {
+		case gatewayWentDown:
+			n.gatewayDown(gs, name, gw.String(), c)
+		case gatewayCameUp:
+			n.gatewayUp(gs, name, gw.String())
+		}
+	}
+}
+
+func (n *Network) gatewayDown(gs *GatewayState, link string, gw string, c *conf.GatewayMonitor) {
+	log.Warnf("Gateway='%s' on link='%s' ifindex='%d' is unreachable", gw, link, gs.IfIndex)
+
+	n.Mutex.Lock()
+	err := gs.demoteDefaultRoutes(c.MetricPenalty)
+	n.Mutex.Unlock()
+
+	if err != nil {
+		log.Errorf("Failed to demote default routes on link='%s' ifindex='%d': %v", link, gs.IfIndex, err)
+	}
+
+	ConfigureMultiPath(n)
+
+	executeGatewayScripts(conf.GatewayDownDir, gs.IfIndex, link, gw)
+}
+
+func (n *Network) gatewayUp(gs *GatewayState, link string, gw string) {
+	log.Infof("Gateway='%s' on link='%s' ifindex='%d' is reachable again", gw, link, gs.IfIndex)
+
+	n.Mutex.Lock()
+	err := gs.restoreDefaultRoutes()
+	n.Mutex.Unlock()
+
+	if err != nil {
+		log.Errorf("Failed to restore default routes on link='%s' ifindex='%d': %v", link, gs.IfIndex, err)
+	}
+
+	ConfigureMultiPath(n)
+
+	executeGatewayScripts(conf.GatewayUpDir, gs.IfIndex, link, gw)
+}
+
+// demoteDefaultRoutes replaces the default routes of the link with ones of a worse metric.
+// Callers must hold n.Mutex.
+func (gs *GatewayState) demoteDefaultRoutes(penalty int) error {
+	if penalty <= 0 {
+		return nil
+	}
+
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := netlink.RouteListFiltered(family, &netlink.Route{LinkIndex: gs.IfIndex, Table: unix.RT_TABLE_MAIN}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return err
+		}
+
+		for _, rt := range routes {
+			if rt.Dst != nil || rt.Gw == nil {
+				continue
+			}
+
+			demoted := rt
+			demoted.Priority = rt.Priority + penalty
+			if err := netlink.RouteAdd(&demoted); err != nil && !errors.Is(err, unix.EEXIST) {
+				return err
+			}
+
+			if err := netlink.RouteDel(&rt); err != nil {
+				return err
+			}
+
+			gs.demoted = append(gs.demoted, demotedRoute{original: rt, demoted: demoted})
+
+			log.Debugf("Demoted default route gw='%s' link='%s' metric='%d' -> '%d'", rt.Gw, gs.Link, rt.Priority, demoted.Priority)
+		}
+	}
+
+	return nil
+}
+
+// restoreDefaultRoutes puts back the default routes demoted. Routes which fail to be restored
+// are kept for the next attempt. Callers must hold n.Mutex.
+func (gs *GatewayState) restoreDefaultRoutes() error {
+	var failed []demotedRoute
+	var err error
+
+	for _, d := range gs.demoted {
+		if e := netlink.RouteAdd(&d.original); e != nil && !errors.Is(e, unix.EEXIST) {
+			log.Debugf("Failed to restore default route gw='%s' link='%s': %v", d.original.Gw, gs.Link, e)
+
+			failed = append(failed, d)
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+
+		if err := netlink.RouteDel(&d.demoted); err != nil {
+			log.Debugf("Failed to remove demoted default route gw='%s' link='%s': %v", d.demoted.Gw, gs.Link, err)
+		}
+
+		log.Debugf("Restored default route gw='%s' link='%s' metric='%d'", d.original.Gw, gs.Link, d.original.Priority)
+	}
+
+	gs.demoted = failed
+
+	return err
+}
+
+func executeGatewayScripts(dir string, index int, link string, gw string) {
+	system.ExecuteScriptsInDir(dir,
+		"LINK="+link,
+		"LINKINDEX="+strconv.Itoa(index),
+		"GATEWAY="+gw,
+	)
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+func TestGatewayStateObserve(t *testing.T) {
+	c := &conf.GatewayMonitor{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+	}
+
+	type probe struct {
+		gw       string
+		answered bool
+		want     int
+	}
+
+	tests := []struct {
+		name   string
+		probes []probe
+	}{
+		{
+			name: "goes down after consecutive failures",
+			probes: []probe{
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayWentDown},
+				{"192.0.2.1", false, gatewayUnchanged},
+			},
+		},
+		{
+			name: "a success resets the failures",
+			probes: []probe{
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", true, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayWentDown},
+			},
+		},
+		{
+			name: "comes up after consecutive successes",
+			probes: []probe{
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayWentDown},
+				{"192.0.2.1", true, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", true, gatewayUnchanged},
+				{"192.0.2.1", true, gatewayCameUp},
+				{"192.0.2.1", true, gatewayUnchanged},
+			},
+		},
+		{
+			name: "a new gateway counts afresh",
+			probes: []probe{
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.1", false, gatewayUnchanged},
+				{"192.0.2.254", false, gatewayUnchanged},
+				{"192.0.2.254", false, gatewayUnchanged},
+				{"192.0.2.254", false, gatewayWentDown},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := &GatewayState{Healthy: true}
+
+			for i, p := range tt.probes {
+				if got := gs.observe(p.gw, p.answered, c); got != p.want {
+					t.Fatalf("Probe %d of gateway='%s' answered='%v': got transition %d, want %d", i, p.gw, p.answered, got, p.want)
+				}
+
+				if gs.Gw != p.gw {
+					t.Fatalf("Probe %d: gateway='%s', want '%s'", i, gs.Gw, p.gw)
+				}
+			}
+		})
+	}
+}
+
+func defaultRouteMetrics(t *testing.T, index int) map[int]bool {
+	t.Helper()
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{LinkIndex: index, Table: unix.RT_TABLE_MAIN}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		t.Fatalf("Failed to list routes: %v", err)
+	}
+
+	metrics := make(map[int]bool)
+	for _, rt := range routes {
+		if rt.Dst == nil {
+			metrics[rt.Priority] = true
+		}
+	}
+
+	return metrics
+}
+
+func TestLookupGatewayWithoutGateway(t *testing.T) {
+	withNetns(t, func(ns netns.NsHandle) {
+		v := newTestVeth(t, ns)
+
+		// 'default dev veth0' as wireguard or ppp links have
+		if err := netlink.RouteAdd(&netlink.Route{LinkIndex: v.Index, Dst: &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, Scope: netlink.SCOPE_LINK}); err != nil {
+			t.Fatalf("Failed to add default route: %v", err)
+		}
+
+		if gw, err := lookupGateway(v.Index); err == nil {
+			t.Fatalf("Found gateway='%s' of a default route without one", gw)
+		}
+
+		if err := netlink.RouteAdd(&netlink.Route{LinkIndex: v.Index, Gw: v.PeerIPv4, Priority: 100}); err != nil {
+			t.Fatalf("Failed to add default route: %v", err)
+		}
+
+		gw, err := lookupGateway(v.Index)
+		if err != nil || !gw.Equal(v.PeerIPv4) {
+			t.Fatalf("Found gateway='%s' err='%v', want '%s'", gw, err, v.PeerIPv4)
+		}
+	})
+}
+
+func TestDemoteRestoreDefaultRoutes(t *testing.T) {
+	withNetns(t, func(ns netns.NsHandle) {
+		v := newTestVeth(t, ns)
+
+		if err := netlink.RouteAdd(&netlink.Route{LinkIndex: v.Index, Gw: v.PeerIPv4, Priority: 100}); err != nil {
+			t.Fatalf("Failed to add default route: %v", err)
+		}
+
+		gs := &GatewayState{IfIndex: v.Index, Link: "veth0", Gw: v.PeerIPv4.String(), Healthy: true}
+
+		if err := gs.demoteDefaultRoutes(1000); err != nil {
+			t.Fatalf("Failed to demote default routes: %v", err)
+		}
+
+		if m := defaultRouteMetrics(t, v.Index); !m[1100] || m[100] {
+			t.Errorf("Default route metrics after demoting='%v', want only 1100", m)
+		}
+
+		if err := gs.restoreDefaultRoutes(); err != nil {
+			t.Fatalf("Failed to restore default routes: %v", err)
+		}
+
+		if m := defaultRouteMetrics(t, v.Index); !m[100] || m[1100] {
+			t.Errorf("Default route metrics after restoring='%v', want only 100", m)
+		}
+
+		if len(gs.demoted) != 0 {
+			t.Errorf("Demoted routes left after restoring: %+v", gs.demoted)
+		}
+	})
+}
+
+func TestRestoreDefaultRoutesKeepsFailed(t *testing.T) {
+	withNetns(t, func(ns netns.NsHandle) {
+		v := newTestVeth(t, ns)
+
+		if err := netlink.RouteAdd(&netlink.Route{LinkIndex: v.Index, Gw: v.PeerIPv4, Priority: 100}); err != nil {
+			t.Fatalf("Failed to add default route: %v", err)
+		}
+
+		gs := &GatewayState{IfIndex: v.Index, Link: "veth0", Gw: v.PeerIPv4.String(), Healthy: true}
+
+		if err := gs.demoteDefaultRoutes(1000); err != nil {
+			t.Fatalf("Failed to demote default routes: %v", err)
+		}
+
+		// A route of a link which is gone cannot be put back
+		gone := netlink.Route{LinkIndex: 9999, Gw: v.PeerIPv4, Priority: 100}
+		gs.demoted = append([]demotedRoute{{original: gone, demoted: gone}}, gs.demoted...)
+
+		if err := gs.restoreDefaultRoutes(); err == nil {
+			t.Fatalf("Restoring a route of a missing link succeeded")
+		}
+
+		if m := defaultRouteMetrics(t, v.Index); !m[100] || m[1100] {
+			t.Errorf("Default route metrics after restoring='%v', want only 100", m)
+		}
+
+		if len(gs.demoted) != 1 || gs.demoted[0].original.LinkIndex != 9999 {
+			t.Errorf("Demoted routes after restoring='%+v', want only the failed one", gs.demoted)
+		}
+	})
+}
+
+func TestDemoteDefaultRoutesWithoutPenalty(t *testing.T) {
+	gs := &GatewayState{IfIndex: 1}
+
+	if err := gs.demoteDefaultRoutes(0); err != nil || len(gs.demoted) != 0 {
+		t.Fatalf("Demoting without penalty: err='%v' demoted='%+v'", err, gs.demoted)
+	}
+}
+
+func TestProbeInvalidGateway(t *testing.T) {
+	p, err := NewProber(ProbeMethodARP)
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+
+	if err := p.Probe(1, nil, 0); err == nil {
+		t.Fatalf("Probing a nil gateway succeeded")
+	}
+
+	if err := p.Probe(1, net.IP{1, 2, 3}, 0); err == nil {
+		t.Fatalf("Probing a gateway of 3 bytes succeeded")
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// withNetns runs f on a thread switched into a new network namespace. The test is skipped when
+// it cannot create one.
+func withNetns(t *testing.T, f func(ns netns.NsHandle)) {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("Creating network namespaces needs root")
+	}
+
+	runtime.LockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("Failed to get network namespace: %v", err)
+	}
+	defer origin.Close()
+
+	ns, err := netns.New()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Skipf("Failed to create network namespace: %v", err)
+	}
+	defer ns.Close()
+
+	// A thread which cannot switch back is not handed on, it ends with the test
+	defer func() {
+		if err := netns.Set(origin); err == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+
+	f(ns)
+}
+
+// testVeth is a veth pair whose peer end sits in a namespace of its own.
+type testVeth struct {
+	Index int
+	Peer  *netlink.Handle
+
+	// Addresses of the local and the peer end
+	IPv4, PeerIPv4 net.IP
+	IPv6, PeerIPv6 net.IP
+}
+
+// newTestVeth creates veth0 in ns, whose peer veth1 is moved to a new namespace, and sets up
+// both ends with addresses of 192.0.2.0/24 and 2001:db8::/64.
+func newTestVeth(t *testing.T, ns netns.NsHandle) *testVeth {
+	t.Helper()
+
+	peerNs, err := netns.New()
+	if err != nil {
+		t.Fatalf("Failed to create peer network namespace: %v", err)
+	}
+	t.Cleanup(func() { peerNs.Close() })
+
+	if err := netns.Set(ns); err != nil {
+		t.Fatalf("Failed to switch back to network namespace: %v", err)
+	}
+
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: "veth0"},
+		PeerName:  "veth1",
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		t.Fatalf("Failed to add veth: %v", err)
+	}
+
+	peer, err := netlink.LinkByName("veth1")
+	if err != nil {
+		t.Fatalf("Failed to find veth peer: %v", err)
+	}
+
+	if err := netlink.LinkSetNsFd(peer, int(peerNs)); err != nil {
+		t.Fatalf("Failed to move veth peer: %v", err)
+	}
+
+	h, err := netlink.NewHandleAt(peerNs)
+	if err != nil {
+		t.Fatalf("Failed to open netlink in peer network namespace: %v", err)
+	}
+	t.Cleanup(h.Delete)
+
+	v := &testVeth{
+		Peer:     h,
+		IPv4:     net.ParseIP("192.0.2.1"),
+		PeerIPv4: net.ParseIP("192.0.2.2"),
+		IPv6:     net.ParseIP("2001:db8::1"),
+		PeerIPv6: net.ParseIP("2001:db8::2"),
+	}
+
+	local, err := netlink.LinkByName("veth0")
+	if err != nil {
+		t.Fatalf("Failed to find veth: %v", err)
+	}
+	v.Index = local.Attrs().Index
+
+	if peer, err = h.LinkByName("veth1"); err != nil {
+		t.Fatalf("Failed to find veth peer: %v", err)
+	}
+
+	for _, e := range []struct {
+		add  func(netlink.Link, *netlink.Addr) error
+		up   func(netlink.Link) error
+		link netlink.Link
+		ips  []net.IP
+	}{
+		{netlink.AddrAdd, netlink.LinkSetUp, local, []net.IP{v.IPv4, v.IPv6}},
+		{h.AddrAdd, h.LinkSetUp, peer, []net.IP{v.PeerIPv4, v.PeerIPv6}},
+	} {
+		for _, ip := range e.ips {
+			mask := net.CIDRMask(24, 32)
+			if ip.To4() == nil {
+				mask = net.CIDRMask(64, 128)
+			}
+
+			a := &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: mask}, Flags: unix.IFA_F_NODAD}
+			if err := e.add(e.link, a); err != nil {
+				t.Fatalf("Failed to add address='%s': %v", ip, err)
+			}
+		}
+
+		if err := e.up(e.link); err != nil {
+			t.Fatalf("Failed to set link up: %v", err)
+		}
+	}
+
+	return v
+}
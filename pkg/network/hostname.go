@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const (
+	HostnamePolicyStatic    = "static"
+	HostnamePolicyTransient = "transient"
+	HostnamePolicyUnset     = "unset"
+
+	// HOST_NAME_MAX of Linux
+	hostnameMaxLength = 64
+)
+
+// HostnamePolicy says how hostnames received on links are set and which link wins when
+// several carry one.
+type HostnamePolicy struct {
+	Mode     string
+	Pretty   bool
+	Priority []*LinkMatcher
+}
+
+func NewHostnamePolicy(c *conf.Config) *HostnamePolicy {
+	p := &HostnamePolicy{
+		Mode:   c.Network.HostnamePolicy,
+		Pretty: c.Network.HostnamePretty,
+	}
+
+	for _, spec := range strings.Fields(c.Network.HostnamePriority) {
+		p.Priority = append(p.Priority, NewLinkMatcher(spec))
+	}
+
+	switch p.Mode {
+	case HostnamePolicyStatic, HostnamePolicyTransient, HostnamePolicyUnset:
+	default:
+		log.Warnf("Unknown HostnamePolicy='%s', falling back to '%s'", p.Mode, HostnamePolicyTransient)
+		p.Mode = HostnamePolicyTransient
+	}
+
+	return p
+}
+
+// rank orders links by the first entry of Priority they match, those matching none last.
+func (p *HostnamePolicy) rank(link string) int {
+	for i, m := range p.Priority {
+		if m.Match(link) {
+			return i
+		}
+	}
+
+	return len(p.Priority)
+}
+
+// HostnameState remembers the hostname set from a link and the ones it replaced.
+type HostnameState struct {
+	IfIndex        int
+	Hostname       string
+	Pretty         string
+	Previous       string
+	PreviousPretty string
+}
+
+// ValidateHostname checks a hostname or FQDN against RFC 1123. A trailing dot is dropped.
+func ValidateHostname(hostname string) (string, error) {
+	hostname = strings.TrimSuffix(hostname, ".")
+	if hostname == "" {
+		return "", fmt.Errorf("empty hostname")
+	}
+
+	if len(hostname) > 253 {
+		return "", fmt.Errorf("hostname='%s' longer than 253 characters", hostname)
+	}
+
+	for _, label := range strings.Split(hostname, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return "", fmt.Errorf("hostname='%s' has a label that is empty or longer than 63 characters", hostname)
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("hostname='%s' has a label starting or ending with '-'", hostname)
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return "", fmt.Errorf("hostname='%s' contains invalid character '%c'", hostname, r)
+			}
+		}
+	}
+
+	if h, _ := SplitHostname(hostname); len(h) > hostnameMaxLength {
+		return "", fmt.Errorf("hostname='%s' longer than %d characters", h, hostnameMaxLength)
+	}
+
+	return hostname, nil
+}
+
+// SplitHostname splits a FQDN into the hostname and the domain.
+func SplitHostname(fqdn string) (string, string) {
+	h, d, _ := strings.Cut(strings.TrimSuffix(fqdn, "."), ".")
+	return h, d
+}
+
+// SetHostnameFromLink records the hostname or FQDN received on a link and sets the one of the
+// winning link following the policy. The hostnames found before the first one set by
+// network-broker are kept to be restored.
+func (n *Network) SetHostnameFromLink(index int, hostname string) error {
+	fqdn, err := ValidateHostname(hostname)
+	if err != nil {
+		return err
+	}
+
+	n.Mutex.Lock()
+	n.HostnamesByIndex[index] = fqdn
+	n.Mutex.Unlock()
+
+	return n.updateHostname("hostname received")
+}
+
+// hostnameWinner returns the link whose hostname is to be set, preferring the links matching
+// earlier patterns of HostnamePriority= and then the lower ifindex. Callers must hold n.Mutex.
+func (n *Network) hostnameWinner() (int, bool) {
+	winner, found := 0, false
+	for index := range n.HostnamesByIndex {
+		if !found {
+			winner, found = index, true
+			continue
+		}
+
+		r, w := n.HostnamePolicy.rank(n.LinksByIndex[index]), n.HostnamePolicy.rank(n.LinksByIndex[winner])
+		if r < w || r == w && index < winner {
+			winner = index
+		}
+	}
+
+	return winner, found
+}
+
+// updateHostname sets the hostname of the winning link, or restores the previous hostnames
+// when no link has one left. Updates are serialized by n.hostnameMutex, n.Mutex is only held
+// to pick the winner so that it is not held across the calls to hostnamed.
+func (n *Network) updateHostname(reason string) error {
+	n.hostnameMutex.Lock()
+	defer n.hostnameMutex.Unlock()
+
+	ctx := context.Background()
+	p := n.HostnamePolicy
+	h := n.Bus.Hostnamed
+
+	n.Mutex.Lock()
+	index, found := n.hostnameWinner()
+	fqdn := n.HostnamesByIndex[index]
+	link := n.LinksByIndex[index]
+	previousLink := ""
+	if n.Hostname != nil {
+		previousLink = n.LinksByIndex[n.Hostname.IfIndex]
+	}
+	n.Mutex.Unlock()
+
+	if !found {
+		if n.Hostname == nil {
+			return nil
+		}
+
+		log.Infof("Restoring hostname='%s' set before link='%s' ifindex='%d': %s", n.Hostname.Previous, previousLink, n.Hostname.IfIndex, reason)
+
+		if err := n.setHostname(n.Hostname.Previous); err != nil {
+			return fmt.Errorf("failed to restore hostname='%s': %w", n.Hostname.Previous, err)
+		}
+
+		if p.Pretty {
+			if err := h.SetPrettyHostname(ctx, n.Hostname.PreviousPretty); err != nil {
+				log.Warnf("Failed to restore pretty hostname='%s': %v", n.Hostname.PreviousPretty, err)
+			}
+		}
+
+		n.Hostname = nil
+		return nil
+	}
+
+	hostname, _ := SplitHostname(fqdn)
+
+	if n.Hostname != nil && n.Hostname.Hostname == hostname && n.Hostname.Pretty == fqdn {
+		n.Hostname.IfIndex = index
+		return nil
+	}
+
+	if p.Mode == HostnamePolicyUnset {
+		static, err := h.StaticHostname(ctx)
+		if err != nil {
+			return err
+		}
+
+		if static != "" {
+			log.Debugf("Not setting hostname='%s' of link='%s' ifindex='%d', static hostname='%s' is set", hostname, link, index, static)
+			return nil
+		}
+	}
+
+	state := n.Hostname
+	if state == nil {
+		previous, err := n.getHostname()
+		if err != nil {
+			return err
+		}
+
+		state = &HostnameState{Previous: previous}
+
+		if p.Pretty {
+			if state.PreviousPretty, err = h.PrettyHostname(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Infof("Setting %s hostname='%s' of link='%s' ifindex='%d': %s", p.Mode, hostname, link, index, reason)
+
+	if err := n.setHostname(hostname); err != nil {
+		return err
+	}
+
+	if p.Pretty {
+		if err := h.SetPrettyHostname(ctx, fqdn); err != nil {
+			log.Warnf("Failed to set pretty hostname='%s': %v", fqdn, err)
+		}
+	}
+
+	state.IfIndex = index
+	state.Hostname = hostname
+	state.Pretty = fqdn
+	n.Hostname = state
+
+	return nil
+}
+
+// getHostname returns the hostname the policy sets.
+func (n *Network) getHostname() (string, error) {
+	if n.HostnamePolicy.Mode == HostnamePolicyStatic {
+		return n.Bus.Hostnamed.StaticHostname(context.Background())
+	}
+
+	return n.Bus.Hostnamed.Hostname(context.Background())
+}
+
+func (n *Network) setHostname(hostname string) error {
+	if n.HostnamePolicy.Mode == HostnamePolicyStatic {
+		return n.Bus.Hostnamed.SetStaticHostname(context.Background(), hostname)
+	}
+
+	return n.Bus.Hostnamed.SetHostname(context.Background(), hostname)
+}
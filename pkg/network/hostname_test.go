@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+func TestValidateHostname(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	long := strings.Repeat(label63+".", 4)
+
+	tests := []struct {
+		hostname string
+		want     string
+		ok       bool
+	}{
+		{"node1", "node1", true},
+		{"node-1.example.com", "node-1.example.com", true},
+		{"node1.example.com.", "node1.example.com", true},
+		{"Node1.Example.COM", "Node1.Example.COM", true},
+		{"1node", "1node", true},
+		{label63, label63, true},
+		{label63 + ".example.com", label63 + ".example.com", true},
+
+		{"", "", false},
+		{".", "", false},
+		{"node1..example.com", "", false},
+		{".example.com", "", false},
+		{"-node1", "", false},
+		{"node1-.example.com", "", false},
+		{"node_1", "", false},
+		{"node 1", "", false},
+		{"nöde1", "", false},
+		{label63 + "a", "", false},
+		{long, "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateHostname(tt.hostname)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ValidateHostname('%s')='%s',%v want '%s',ok=%v", tt.hostname, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSplitHostname(t *testing.T) {
+	tests := []struct {
+		fqdn     string
+		hostname string
+		domain   string
+	}{
+		{"node1", "node1", ""},
+		{"node1.example.com", "node1", "example.com"},
+		{"node1.lab.example.com.", "node1", "lab.example.com"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if h, d := SplitHostname(tt.fqdn); h != tt.hostname || d != tt.domain {
+			t.Errorf("SplitHostname('%s')='%s','%s' want '%s','%s'", tt.fqdn, h, d, tt.hostname, tt.domain)
+		}
+	}
+}
+
+func TestHostnameWinner(t *testing.T) {
+	tests := []struct {
+		priority  string
+		hostnames map[int]string
+		want      int
+	}{
+		{"", map[int]string{3: "c", 2: "b", 5: "e"}, 2},
+		{"wlan*", map[int]string{2: "b", 3: "c"}, 3},
+		{"wlan* eth1", map[int]string{1: "a", 2: "b"}, 2},
+		{"eth1 wlan*", map[int]string{2: "b", 3: "c"}, 2},
+	}
+
+	for _, tt := range tests {
+		n := &Network{
+			LinksByIndex:     map[int]string{1: "eth0", 2: "eth1", 3: "wlan0", 5: "eth2"},
+			HostnamesByIndex: tt.hostnames,
+			HostnamePolicy: NewHostnamePolicy(&conf.Config{Network: conf.Network{
+				HostnamePolicy:   HostnamePolicyTransient,
+				HostnamePriority: tt.priority,
+			}}),
+		}
+
+		if got, found := n.hostnameWinner(); !found || got != tt.want {
+			t.Errorf("Winner of priority='%s' hostnames='%v'=%d, want %d", tt.priority, tt.hostnames, got, tt.want)
+		}
+	}
+}
@@ -24,6 +24,9 @@ func AcquireLinks(n *Network) error {
 
 		n.LinksByName[link.Attrs().Name] = link.Attrs().Index
 		n.LinksByIndex[link.Attrs().Index] = link.Attrs().Name
+		n.TopologyByIndex[link.Attrs().Index] = linkTopology(link)
+		n.LinkPropertiesByIndex[link.Attrs().Index] = linkProperties(link)
+		n.acquireAddressFlags(link)
 
 		log.Debugf("Acquired link='%s' ifindex='%d' from netlink message", link.Attrs().Name, link.Attrs().Index)
 	}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/configfile"
+)
+
+const (
+	matchMACAddress      = "MACAddress"
+	matchDriver          = "Driver"
+	matchKind            = "Kind"
+	matchPath            = "Path"
+	matchAlternativeName = "AlternativeName"
+)
+
+type matchTerm struct {
+	key     string
+	pattern string
+	fold    bool
+}
+
+// LinkMatcher matches links against a whitespace-separated list of terms. A bare term is
+// an exact link name or a shell glob. A 'Key=value' term matches like the [Match] section
+// of a systemd .link file on one of MACAddress=, Driver=, Kind=, Path= or AlternativeName=.
+// Values of predicates may be globs as well. As in a .link file a link matches when, for each
+// of the names and the keys given, one of the terms matches. Unknown keys match no link.
+type LinkMatcher struct {
+	terms []matchTerm
+}
+
+func NewLinkMatcher(spec string) *LinkMatcher {
+	return newLinkMatcher(spec, false)
+}
+
+// NewLinkMatcherFold returns a matcher of a pattern read from the key of a section. As viper
+// lowercases keys, names and alternative names are matched in lower case.
+func NewLinkMatcherFold(spec string) *LinkMatcher {
+	return newLinkMatcher(spec, true)
+}
+
+func newLinkMatcher(spec string, fold bool) *LinkMatcher {
+	m := &LinkMatcher{}
+
+	for _, t := range strings.Fields(spec) {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			m.terms = append(m.terms, matchTerm{pattern: t, fold: fold})
+			continue
+		}
+
+		// Keys are case insensitive as viper lowercases the keys of a section
+		for _, key := range []string{matchMACAddress, matchDriver, matchKind, matchPath, matchAlternativeName} {
+			if strings.EqualFold(k, key) {
+				k = key
+			}
+		}
+
+		switch k {
+		case matchMACAddress:
+			v = strings.ToLower(v)
+		case matchDriver, matchKind, matchPath, matchAlternativeName:
+		default:
+			// Keep the term so that it never matches, an empty matcher would match all links
+			log.Warnf("Unknown link match predicate='%s', no link matches it", t)
+		}
+
+		m.terms = append(m.terms, matchTerm{key: k, pattern: v, fold: fold})
+	}
+
+	return m
+}
+
+func (m *LinkMatcher) IsEmpty() bool {
+	return len(m.terms) == 0
+}
+
+// HasPredicates reports whether the matcher needs more than the link name.
+func (m *LinkMatcher) HasPredicates() bool {
+	for _, t := range m.terms {
+		if t.key != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func globMatch(pattern string, s string) bool {
+	if s == "" {
+		return false
+	}
+
+	ok, err := filepath.Match(pattern, s)
+	if err != nil {
+		return pattern == s
+	}
+
+	return ok
+}
+
+// Match reports whether the link with the given name matches.
+func (m *LinkMatcher) Match(name string) bool {
+	if name == "" || m.IsEmpty() {
+		return false
+	}
+
+	link := netlink.Link(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name}})
+	if m.HasPredicates() {
+		// Look up the link only when the names match
+		if !m.matchKey("", link) {
+			return false
+		}
+
+		l, err := netlink.LinkByName(name)
+		if err != nil {
+			return false
+		}
+		link = l
+	}
+
+	return m.matchLink(link)
+}
+
+// MatchLink reports whether the given link matches.
+func (m *LinkMatcher) MatchLink(link netlink.Link) bool {
+	if m.IsEmpty() {
+		return false
+	}
+
+	return m.matchLink(link)
+}
+
+func (m *LinkMatcher) matchLink(link netlink.Link) bool {
+	for _, t := range m.terms {
+		if !m.matchKey(t.key, link) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchKey reports whether one of the terms of the key matches the link.
+func (m *LinkMatcher) matchKey(key string, link netlink.Link) bool {
+	found := false
+	for _, t := range m.terms {
+		if t.key != key {
+			continue
+		}
+
+		if t.match(link) {
+			return true
+		}
+		found = true
+	}
+
+	return !found
+}
+
+func (t *matchTerm) matchName(name string) bool {
+	if t.fold {
+		name = strings.ToLower(name)
+	}
+
+	return globMatch(t.pattern, name)
+}
+
+func (t *matchTerm) match(link netlink.Link) bool {
+	switch t.key {
+	case "":
+		return t.matchName(link.Attrs().Name)
+	case matchMACAddress:
+		return globMatch(t.pattern, link.Attrs().HardwareAddr.String())
+	case matchKind:
+		return globMatch(t.pattern, link.Type())
+	case matchDriver:
+		return globMatch(t.pattern, linkDriver(link.Attrs().Name))
+	case matchPath:
+		return globMatch(t.pattern, linkPath(link.Attrs().Name))
+	case matchAlternativeName:
+		for _, name := range linkAltNames(link.Attrs().Index) {
+			if t.matchName(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// linkAltNames returns the alternative names of a link, which netlink.LinkAttrs lacks.
+func linkAltNames(index int) []string {
+	if index <= 0 {
+		return nil
+	}
+
+	req := nl.NewNetlinkRequest(unix.RTM_GETLINK, unix.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(index)
+	req.AddData(msg)
+
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWLINK)
+	if err != nil || len(msgs) == 0 || len(msgs[0]) < unix.SizeofIfInfomsg {
+		return nil
+	}
+
+	attrs, err := nl.ParseRouteAttr(msgs[0][unix.SizeofIfInfomsg:])
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, a := range attrs {
+		if a.Attr.Type&^unix.NLA_F_NESTED != unix.IFLA_PROP_LIST {
+			continue
+		}
+
+		props, err := nl.ParseRouteAttr(a.Value)
+		if err != nil {
+			continue
+		}
+
+		for _, p := range props {
+			if p.Attr.Type == unix.IFLA_ALT_IFNAME {
+				names = append(names, string(bytes.TrimRight(p.Value, "\x00")))
+			}
+		}
+	}
+
+	return names
+}
+
+func linkDriver(name string) string {
+	driver, err := configfile.ParseKeyFromSectionString(path.Join("/sys/class/net", name, "device/uevent"), "", "DRIVER")
+	if err != nil {
+		return ""
+	}
+
+	return driver
+}
+
+func linkPath(name string) string {
+	slot, err := configfile.ParseKeyFromSectionString(path.Join("/sys/class/net", name, "device/uevent"), "", "PCI_SLOT_NAME")
+	if err != nil {
+		return ""
+	}
+
+	return "pci-" + slot
+}
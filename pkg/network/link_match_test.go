@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"os/exec"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+func TestLinkMatcher(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:aa:bb:cc")
+
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", HardwareAddr: mac}}
+	eth10 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth10"}}
+	vlan := &netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.10"}, VlanId: 10}
+	bond := &netlink.Bond{LinkAttrs: netlink.LinkAttrs{Name: "bond0"}}
+
+	tests := []struct {
+		spec  string
+		link  netlink.Link
+		match bool
+	}{
+		{"", eth0, false},
+		{"eth0", eth0, true},
+		{"eth1", eth10, false},
+		{"eth1", eth0, false},
+		{"eth1 eth10", eth10, true},
+		{"eth*", eth10, true},
+		{"eth?", eth10, false},
+		{"eth[0-9]", eth0, true},
+		{"MACAddress=00:11:22:AA:BB:CC", eth0, true},
+		{"macaddress=00:11:22:*", eth0, true},
+		{"MACAddress=00:11:22:*", eth10, false},
+		{"Kind=vlan", vlan, true},
+		{"Kind=vlan", eth0, false},
+		{"Kind=vlan Kind=bond", bond, true},
+		{"kind=bo*", bond, true},
+
+		// Every predicate type given must match
+		{"eth* Kind=vlan", vlan, true},
+		{"eth* Kind=vlan", eth0, false},
+		{"eth* Kind=vlan", bond, false},
+		{"eth1* eth0* Kind=vlan", vlan, true},
+		{"Kind=vlan MACAddress=00:11:22:*", vlan, false},
+
+		// Unknown predicates match no link
+		{"Unknown=x", eth0, false},
+		{"eth0 Unknown=x", eth0, false},
+
+		// Invalid globs match exactly
+		{"eth[", &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth["}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := NewLinkMatcher(tt.spec).MatchLink(tt.link); got != tt.match {
+			t.Errorf("NewLinkMatcher('%s').MatchLink('%s')=%v, want %v", tt.spec, tt.link.Attrs().Name, got, tt.match)
+		}
+	}
+}
+
+func TestLinkMatcherUnknownPredicate(t *testing.T) {
+	// Filters like Links= treat an empty matcher as matching all links
+	if m := NewLinkMatcher("Unknown=x"); m.IsEmpty() {
+		t.Fatalf("Matcher of an unknown predicate is empty")
+	}
+}
+
+func TestLinkMatcherMatchName(t *testing.T) {
+	tests := []struct {
+		spec  string
+		name  string
+		match bool
+	}{
+		{"eth0 eth1", "eth1", true},
+		{"eth0 eth1", "eth", false},
+		{"eth*", "", false},
+		{"*", "lo", true},
+	}
+
+	for _, tt := range tests {
+		if got := NewLinkMatcher(tt.spec).Match(tt.name); got != tt.match {
+			t.Errorf("NewLinkMatcher('%s').Match('%s')=%v, want %v", tt.spec, tt.name, got, tt.match)
+		}
+	}
+}
+
+func TestLinkMatcherFold(t *testing.T) {
+	tests := []struct {
+		spec  string
+		name  string
+		fold  bool
+		match bool
+	}{
+		{"wan0", "WAN0", false, false},
+		{"wan*", "WAN0", true, true},
+		{"wan0", "wan0", true, true},
+		{"lan*", "WAN0", true, false},
+	}
+
+	for _, tt := range tests {
+		m := NewLinkMatcher(tt.spec)
+		if tt.fold {
+			m = NewLinkMatcherFold(tt.spec)
+		}
+
+		if got := m.Match(tt.name); got != tt.match {
+			t.Errorf("Matcher of '%s' fold='%v' Match('%s')=%v, want %v", tt.spec, tt.fold, tt.name, got, tt.match)
+		}
+	}
+}
+
+func TestLinkMatcherKernel(t *testing.T) {
+	withNetns(t, func(ns netns.NsHandle) {
+		newTestVeth(t, ns)
+
+		ip, err := exec.LookPath("ip")
+		if err != nil {
+			t.Skip("ip not found")
+		}
+
+		if out, err := exec.Command(ip, "link", "property", "add", "dev", "veth0", "altname", "uplink0").CombinedOutput(); err != nil {
+			t.Skipf("Failed to add alternative name: %v: %s", err, out)
+		}
+
+		tests := []struct {
+			spec  string
+			match bool
+		}{
+			{"Kind=veth", true},
+			{"Kind=vlan", false},
+			{"veth* Kind=veth", true},
+			{"AlternativeName=uplink0", true},
+			{"AlternativeName=uplink*", true},
+			{"AlternativeName=downlink*", false},
+			{"veth0 AlternativeName=uplink*", true},
+			{"veth1 AlternativeName=uplink*", false},
+		}
+
+		for _, tt := range tests {
+			if got := NewLinkMatcher(tt.spec).Match("veth0"); got != tt.match {
+				t.Errorf("NewLinkMatcher('%s').Match('veth0')=%v, want %v", tt.spec, got, tt.match)
+			}
+		}
+	})
+}
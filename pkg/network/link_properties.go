@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+// LinkProperties holds the link attributes whose changes are reported via link-changed.d.
+type LinkProperties struct {
+	MTU          string
+	HardwareAddr string
+	Flags        string
+	Promisc      string
+	TxQLen       string
+	Alias        string
+	Speed        string
+	Duplex       string
+}
+
+func readLinkSysfs(link string, attr string) string {
+	b, err := os.ReadFile(path.Join("/sys/class/net", link, attr))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func linkProperties(link netlink.Link) *LinkProperties {
+	attrs := link.Attrs()
+
+	p := &LinkProperties{
+		MTU:          strconv.Itoa(attrs.MTU),
+		HardwareAddr: attrs.HardwareAddr.String(),
+		Flags:        attrs.Flags.String(),
+		Promisc:      strconv.FormatBool(attrs.RawFlags&unix.IFF_PROMISC != 0),
+		TxQLen:       strconv.Itoa(attrs.TxQLen),
+		Alias:        attrs.Alias,
+	}
+
+	// The negotiated speed and duplex as reported by ethtool. Unknown while there is no carrier
+	p.Speed = readLinkSysfs(attrs.Name, "speed")
+	if p.Speed == "-1" {
+		p.Speed = "unknown"
+	}
+	p.Duplex = readLinkSysfs(attrs.Name, "duplex")
+
+	return p
+}
+
+type linkProperty struct {
+	name string
+	old  string
+	cur  string
+}
+
+func (old *LinkProperties) diff(cur *LinkProperties) []linkProperty {
+	all := []linkProperty{
+		{"mtu", old.MTU, cur.MTU},
+		{"hwaddr", old.HardwareAddr, cur.HardwareAddr},
+		{"flags", old.Flags, cur.Flags},
+		{"promisc", old.Promisc, cur.Promisc},
+		{"txqlen", old.TxQLen, cur.TxQLen},
+		{"alias", old.Alias, cur.Alias},
+		{"speed", old.Speed, cur.Speed},
+		{"duplex", old.Duplex, cur.Duplex},
+	}
+
+	var changed []linkProperty
+	for _, p := range all {
+		if p.old != p.cur {
+			changed = append(changed, p)
+		}
+	}
+
+	return changed
+}
+
+// updateLinkProperties diffs the attributes of a link with the previous update and executes
+// the scripts in link-changed.d.
+func (n *Network) updateLinkProperties(link netlink.Link, c *conf.Config) {
+	index := link.Attrs().Index
+	cur := linkProperties(link)
+
+	n.Mutex.Lock()
+	old, ok := n.LinkPropertiesByIndex[index]
+	n.LinkPropertiesByIndex[index] = cur
+	n.Mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	changed := old.diff(cur)
+	if len(changed) == 0 {
+		return
+	}
+
+	links := NewLinkMatcher(c.Network.Links)
+	if !links.IsEmpty() && !links.MatchLink(link) {
+		return
+	}
+
+	var names []string
+	env := []string{
+		"LINK=" + link.Attrs().Name,
+		"LINKINDEX=" + strconv.Itoa(index),
+	}
+
+	for _, p := range changed {
+		log.Infof("Link='%s' ifindex='%d' changed '%s' from '%s' to '%s'", link.Attrs().Name, index, p.name, p.old, p.cur)
+
+		names = append(names, p.name)
+		env = append(env,
+			strings.ToUpper(p.name)+"="+p.cur,
+			"OLD_"+strings.ToUpper(p.name)+"="+p.old,
+		)
+	}
+
+	env = append(env, "CHANGED="+strings.Join(names, ","))
+
+	system.ExecuteScriptsInDir(conf.LinkChangedDir, env...)
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const (
+	nftMasqueradeV4Map = "masquerade_v4"
+	nftMasqueradeV6Map = "masquerade_v6"
+)
+
+type masqueradeLink struct {
+	name string
+	v4   string
+	v6   string
+}
+
+// Masquerade keeps one SNAT map element per family for each matching link, pointing to
+// the current primary address of the link.
+type Masquerade struct {
+	Links *LinkMatcher
+
+	mutex sync.Mutex
+	ready bool
+	links map[int]*masqueradeLink
+}
+
+func NewMasquerade(c *conf.Config) *Masquerade {
+	return &Masquerade{
+		Links: NewLinkMatcher(c.Network.Masquerade),
+		links: make(map[int]*masqueradeLink),
+	}
+}
+
+// setupMasquerade creates the postrouting chain which SNATs packets leaving the links in
+// the maps to the address of the link.
+func setupMasquerade() error {
+	return nftApply(
+		"add table "+NftTableFamily+" "+NftTableName,
+		nftObject("add map", nftMasqueradeV4Map)+" { type ifname : ipv4_addr; }",
+		nftObject("add map", nftMasqueradeV6Map)+" { type ifname : ipv6_addr; }",
+		nftObject("add chain", "postrouting")+" { type nat hook postrouting priority srcnat; policy accept; }",
+		nftObject("flush chain", "postrouting"),
+		nftObject("flush map", nftMasqueradeV4Map),
+		nftObject("flush map", nftMasqueradeV6Map),
+		nftObject("add rule", "postrouting")+" meta nfproto ipv4 snat ip to oifname map @"+nftMasqueradeV4Map,
+		nftObject("add rule", "postrouting")+" meta nfproto ipv6 snat ip6 to oifname map @"+nftMasqueradeV6Map,
+	)
+}
+
+// primaryAddress returns the first usable global address of the family on the link.
+func primaryAddress(link netlink.Link, family int) string {
+	addrs, err := netlink.AddrList(link, family)
+	if err != nil {
+		return ""
+	}
+
+	for _, a := range addrs {
+		if a.Scope != unix.RT_SCOPE_UNIVERSE {
+			continue
+		}
+
+		if a.Flags&(unix.IFA_F_SECONDARY|unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED|unix.IFA_F_DEPRECATED) != 0 {
+			continue
+		}
+
+		return a.IP.String()
+	}
+
+	return ""
+}
+
+func masqueradeElement(command string, m string, link string, addr string) string {
+	e := "{ \"" + link + "\""
+	if addr != "" {
+		e += " : " + addr
+	}
+
+	return nftObject(command, m) + " " + e + " }"
+}
+
+// UpdateMasquerade points the SNAT map elements of a link to its current primary addresses,
+// or removes them when the link is gone or no longer matches.
+func (n *Network) UpdateMasquerade(index int) {
+	m := n.Masquerade
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.ready {
+		if err := setupMasquerade(); err != nil {
+			log.Warnf("Failed to setup nftables masquerade rules: %v", err)
+			return
+		}
+
+		m.ready = true
+	}
+
+	cur := &masqueradeLink{}
+
+	link, err := netlink.LinkByIndex(index)
+	if err == nil && m.Links.MatchLink(link) {
+		cur.name = link.Attrs().Name
+		cur.v4 = primaryAddress(link, netlink.FAMILY_V4)
+		cur.v6 = primaryAddress(link, netlink.FAMILY_V6)
+	}
+
+	old, ok := m.links[index]
+	if !ok {
+		old = &masqueradeLink{}
+	}
+
+	if *old == *cur {
+		return
+	}
+
+	var commands []string
+	for _, e := range []struct {
+		m        string
+		old, cur string
+	}{
+		{nftMasqueradeV4Map, old.v4, cur.v4},
+		{nftMasqueradeV6Map, old.v6, cur.v6},
+	} {
+		if e.old == e.cur && old.name == cur.name {
+			continue
+		}
+
+		if e.old != "" {
+			commands = append(commands, masqueradeElement("delete element", e.m, old.name, ""))
+		}
+		if e.cur != "" {
+			commands = append(commands, masqueradeElement("add element", e.m, cur.name, e.cur))
+		}
+	}
+
+	if len(commands) > 0 {
+		if err := nftApply(commands...); err != nil {
+			log.Warnf("Failed to update masquerade of link='%s' ifindex='%d': %v", cur.name, index, err)
+			return
+		}
+	}
+
+	if cur.name == "" {
+		delete(m.links, index)
+
+		log.Infof("Stopped masquerading link='%s' ifindex='%d'", old.name, index)
+		return
+	}
+
+	m.links[index] = cur
+
+	log.Infof("Masquerading link='%s' ifindex='%d' to ipv4='%s' ipv6='%s'", cur.name, index, cur.v4, cur.v6)
+}
+
+// UpdateAllMasquerades applies the masquerade of all links known at startup.
+func (n *Network) UpdateAllMasquerades() {
+	n.Mutex.Lock()
+	indexes := make([]int, 0, len(n.LinksByIndex))
+	for index := range n.LinksByIndex {
+		indexes = append(indexes, index)
+	}
+	n.Mutex.Unlock()
+
+	for _, index := range indexes {
+		n.UpdateMasquerade(index)
+	}
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+// MultiPath keeps one weighted ECMP default route in the main table spanning all
+// routable links listed in RoutingPolicyRules=.
+type MultiPath struct {
+	Links   *LinkMatcher
+	Weights map[string]int
+	Metric  int
+
+	installed *netlink.Route
+}
+
+func NewMultiPath(c *conf.Config) *MultiPath {
+	m := &MultiPath{
+		Links:   NewLinkMatcher(c.Network.RoutingPolicyRules),
+		Weights: make(map[string]int),
+		Metric:  c.Network.MultiPathMetric,
+	}
+
+	for _, w := range strings.Fields(c.Network.MultiPathWeights) {
+		s := strings.SplitN(w, ":", 2)
+		if len(s) != 2 {
+			log.Warnf("Ignoring invalid multipath weight='%s'", w)
+			continue
+		}
+
+		weight, err := strconv.Atoi(s[1])
+		if err != nil || weight < 1 || weight > 256 {
+			log.Warnf("Ignoring invalid multipath weight='%s'", w)
+			continue
+		}
+
+		m.Weights[s[0]] = weight
+	}
+
+	return m
+}
+
+func (m *MultiPath) weight(link string) int {
+	w, ok := m.Weights[link]
+	if !ok {
+		return 1
+	}
+
+	return w
+}
+
+// SwapLinkRoutable records whether a link is routable and rebuilds the multipath default route
+// when the set of routable links changed. It returns whether the link was routable before, so
+// that of concurrent updates only one sees the change.
+func (n *Network) SwapLinkRoutable(index int, routable bool) bool {
+	n.Mutex.Lock()
+	old := n.RoutableLinks[index]
+	if routable {
+		n.RoutableLinks[index] = true
+	} else {
+		delete(n.RoutableLinks, index)
+	}
+	n.Mutex.Unlock()
+
+	if old != routable {
+		ConfigureMultiPath(n)
+	}
+
+	return old
+}
+
+func (n *Network) IsLinkRoutable(index int) bool {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+
+	return n.RoutableLinks[index]
+}
+
+func (m *MultiPath) nexthops(n *Network) []*netlink.NexthopInfo {
+	var nexthops []*netlink.NexthopInfo
+
+	for index := range n.RoutableLinks {
+		link := n.LinksByIndex[index]
+		if !m.Links.Match(link) {
+			continue
+		}
+
+		if !n.IsGatewayHealthy(index) {
+			log.Debugf("Excluding link='%s' ifindex='%d' from multipath default route: gateway is down", link, index)
+			continue
+		}
+
+		gw, err := GetDefaultIpv4GatewayByLink(index)
+		if err != nil {
+			gw, err = GetIpv4GatewayByLink(index)
+			if err != nil {
+				continue
+			}
+		}
+
+		nexthops = append(nexthops, &netlink.NexthopInfo{
+			LinkIndex: index,
+			Gw:        net.ParseIP(gw).To4(),
+			Hops:      m.weight(link) - 1,
+		})
+	}
+
+	sort.Slice(nexthops, func(i, j int) bool {
+		return nexthops[i].LinkIndex < nexthops[j].LinkIndex
+	})
+
+	return nexthops
+}
+
+func nexthopsEqual(a, b []*netlink.NexthopInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].LinkIndex != b[i].LinkIndex || a[i].Hops != b[i].Hops || !a[i].Gw.Equal(b[i].Gw) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ConfigureMultiPath rebuilds the multipath default route from the routable links and their gateways.
+func ConfigureMultiPath(n *Network) error {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+
+	m := n.MultiPath
+	if m == nil {
+		return nil
+	}
+
+	nexthops := m.nexthops(n)
+
+	if m.installed != nil && nexthopsEqual(m.installed.MultiPath, nexthops) {
+		return nil
+	}
+
+	if len(nexthops) == 0 {
+		if m.installed != nil {
+			log.Debugf("Dropping multipath default route metric='%d'", m.Metric)
+
+			if err := netlink.RouteDel(m.installed); err != nil {
+				log.Warnf("Failed to drop multipath default route: %v", err)
+			}
+			m.installed = nil
+		}
+
+		return nil
+	}
+
+	rt := &netlink.Route{
+		Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+		MultiPath: nexthops,
+		Priority:  m.Metric,
+		Table:     unix.RT_TABLE_MAIN,
+		Protocol:  unix.RTPROT_STATIC,
+	}
+
+	if err := netlink.RouteReplace(rt); err != nil {
+		log.Errorf("Failed to configure multipath default route nexthops='%v': %v", nexthops, err)
+		return err
+	}
+
+	m.installed = rt
+
+	log.Infof("Configured multipath default route nexthops='%v' metric='%d'", nexthops, m.Metric)
+
+	return nil
+}
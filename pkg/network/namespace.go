@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	NamespaceEventLinkAdded      = "link-added"
+	NamespaceEventLinkRemoved    = "link-removed"
+	NamespaceEventAddressAdded   = "address-added"
+	NamespaceEventAddressRemoved = "address-removed"
+	NamespaceEventRouteChanged   = "route-changed"
+
+	nsfsMagic = 0x6e736673
+)
+
+// NamespaceWatcher subscribes to link, address and route updates of the network namespaces
+// bind mounted into the configured directories, e.g. /run/netns.
+type NamespaceWatcher struct {
+	c     *conf.Namespace
+	names []string
+
+	mutex   sync.Mutex
+	watched map[string]chan struct{}
+}
+
+func WatchNamespaces(c *conf.Config) {
+	w := &NamespaceWatcher{
+		c:       &c.Namespace,
+		names:   strings.Fields(c.Namespace.Names),
+		watched: make(map[string]chan struct{}),
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("Failed to watch network namespaces: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	for _, dir := range strings.Fields(c.Namespace.Paths) {
+		if err := watcher.Add(dir); err != nil {
+			log.Warnf("Failed to watch network namespace dir='%s': %v", dir, err)
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			w.add(path.Join(dir, e.Name()))
+		}
+	}
+
+	log.Infof("Listening to network namespace events in '%s'", c.Namespace.Paths)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			switch {
+			case event.Has(fsnotify.Create):
+				w.add(event.Name)
+			case event.Has(fsnotify.Remove):
+				w.remove(event.Name)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Errorf("Received error from network namespace watcher: %v", err)
+		}
+	}
+}
+
+func (w *NamespaceWatcher) add(nsPath string) {
+	name := path.Base(nsPath)
+	if !w.isWatched(name) {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.watched[nsPath]; ok {
+		return
+	}
+
+	done := make(chan struct{})
+	w.watched[nsPath] = done
+
+	go w.watch(nsPath, done)
+}
+
+func (w *NamespaceWatcher) remove(nsPath string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	done, ok := w.watched[nsPath]
+	if !ok {
+		return
+	}
+
+	log.Infof("Network namespace='%s' removed", path.Base(nsPath))
+
+	close(done)
+	delete(w.watched, nsPath)
+}
+
+// forget drops the namespace when its watcher stops on its own, so that it is watched again
+// once it is recreated, and closes done to end the subscriptions already made.
+func (w *NamespaceWatcher) forget(nsPath string, done chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.watched[nsPath] != done {
+		return
+	}
+
+	close(done)
+	delete(w.watched, nsPath)
+}
+
+func (w *NamespaceWatcher) isWatched(name string) bool {
+	for _, pattern := range w.names {
+		if globMatch(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func openNamespace(nsPath string) (netns.NsHandle, error) {
+	var err error
+
+	// ip-netns(8) creates the file before bind mounting the namespace on it
+	for i := 0; i < 10; i++ {
+		var ns netns.NsHandle
+
+		ns, err = netns.GetFromPath(nsPath)
+		if err == nil {
+			var st syscall.Statfs_t
+			if err = syscall.Fstatfs(int(ns), &st); err == nil && st.Type == nsfsMagic {
+				return ns, nil
+			}
+
+			ns.Close()
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err == nil {
+		err = syscall.EINVAL
+	}
+
+	return netns.None(), err
+}
+
+func (w *NamespaceWatcher) watch(nsPath string, done chan struct{}) {
+	name := path.Base(nsPath)
+
+	// netlink closes the channels only after its receive goroutines saw done, which they
+	// miss while blocked sending an update. Keep reading until they are closed.
+	var drains []func()
+	defer func() {
+		w.forget(nsPath, done)
+
+		for _, drain := range drains {
+			go drain()
+		}
+	}()
+
+	ns, err := openNamespace(nsPath)
+	if err != nil {
+		log.Errorf("Failed to open network namespace='%s': %v", name, err)
+		return
+	}
+	defer ns.Close()
+
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		log.Errorf("Failed to open netlink handle in network namespace='%s': %v", name, err)
+		return
+	}
+	defer h.Delete()
+
+	linkName := func(index int) string {
+		l, err := h.LinkByIndex(index)
+		if err != nil {
+			return ""
+		}
+
+		return l.Attrs().Name
+	}
+
+	errorCallback := func(err error) {
+		log.Errorf("Received error from network namespace='%s' subscription: %v", name, err)
+	}
+
+	links := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{Namespace: &ns, ErrorCallback: errorCallback}); err != nil {
+		log.Errorf("Failed to subscribe link update in network namespace='%s': %v", name, err)
+		return
+	}
+	drains = append(drains, func() {
+		for range links {
+		}
+	})
+
+	addresses := make(chan netlink.AddrUpdate)
+	if err := netlink.AddrSubscribeWithOptions(addresses, done, netlink.AddrSubscribeOptions{Namespace: &ns, ErrorCallback: errorCallback}); err != nil {
+		log.Errorf("Failed to subscribe IP address update in network namespace='%s': %v", name, err)
+		return
+	}
+	drains = append(drains, func() {
+		for range addresses {
+		}
+	})
+
+	routes := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribeWithOptions(routes, done, netlink.RouteSubscribeOptions{Namespace: &ns, ErrorCallback: errorCallback}); err != nil {
+		log.Errorf("Failed to subscribe route update in network namespace='%s': %v", name, err)
+		return
+	}
+	drains = append(drains, func() {
+		for range routes {
+		}
+	})
+
+	log.Infof("Listening to events in network namespace='%s'", name)
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case u, ok := <-links:
+			if !ok {
+				return
+			}
+
+			event := NamespaceEventLinkAdded
+			if u.Header.Type == syscall.RTM_DELLINK {
+				event = NamespaceEventLinkRemoved
+			}
+
+			w.executeScripts(nsPath, event, u.Attrs().Name, u.Attrs().Index)
+
+		case u, ok := <-addresses:
+			if !ok {
+				return
+			}
+
+			event := NamespaceEventAddressAdded
+			if !u.NewAddr {
+				event = NamespaceEventAddressRemoved
+			}
+
+			mask, _ := u.LinkAddress.Mask.Size()
+			w.executeScripts(nsPath, event, linkName(u.LinkIndex), u.LinkIndex, "ADDRESS="+u.LinkAddress.IP.String()+"/"+strconv.Itoa(mask))
+
+		case u, ok := <-routes:
+			if !ok {
+				return
+			}
+
+			w.executeScripts(nsPath, NamespaceEventRouteChanged, linkName(u.LinkIndex), u.LinkIndex)
+		}
+	}
+}
+
+func (w *NamespaceWatcher) executeScripts(nsPath string, event string, link string, index int, extra ...string) {
+	name := path.Base(nsPath)
+
+	log.Debugf("Network namespace='%s' event='%s' link='%s' ifindex='%d'", name, event, link, index)
+
+	env := append([]string{
+		"NETNS=" + name,
+		"NETNS_PATH=" + nsPath,
+		"EVENT=" + event,
+		"LINK=" + link,
+		"LINKINDEX=" + strconv.Itoa(index),
+	}, extra...)
+
+	if w.c.RunScriptsInNamespace {
+		if err := system.ExecuteScriptsInNamespace(conf.NamespaceEventsDir, nsPath, env...); err != nil {
+			log.Errorf("Failed to execute scripts in network namespace='%s': %v", name, err)
+		}
+
+		return
+	}
+
+	system.ExecuteScriptsInDir(conf.NamespaceEventsDir, env...)
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+func TestNamespaceWatcherForgetsFailed(t *testing.T) {
+	// A file which is no network namespace cannot be opened
+	nsPath := filepath.Join(t.TempDir(), "ns0")
+	if err := os.WriteFile(nsPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to write '%s': %v", nsPath, err)
+	}
+
+	w := &NamespaceWatcher{
+		c:       &conf.Namespace{},
+		names:   []string{"ns*"},
+		watched: make(map[string]chan struct{}),
+	}
+
+	w.add(nsPath)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		w.mutex.Lock()
+		_, ok := w.watched[nsPath]
+		w.mutex.Unlock()
+
+		if !ok {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("Namespace='%s' still watched after failing to open it", nsPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Removing it afterwards is harmless
+	w.remove(nsPath)
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	NeighborEventStateChanged  = "state-changed"
+	NeighborEventLLAddrChanged = "lladdr-changed"
+	NeighborEventRemoved       = "removed"
+)
+
+var neighborStates = map[int]string{
+	netlink.NUD_NONE:       "none",
+	netlink.NUD_INCOMPLETE: "incomplete",
+	netlink.NUD_REACHABLE:  "reachable",
+	netlink.NUD_STALE:      "stale",
+	netlink.NUD_DELAY:      "delay",
+	netlink.NUD_PROBE:      "probe",
+	netlink.NUD_FAILED:     "failed",
+	netlink.NUD_NOARP:      "noarp",
+	netlink.NUD_PERMANENT:  "permanent",
+}
+
+func neighborState(state int) string {
+	s, ok := neighborStates[state]
+	if !ok {
+		return strconv.Itoa(state)
+	}
+
+	return s
+}
+
+type neighbor struct {
+	state  string
+	lladdr string
+}
+
+type neighborWatcher struct {
+	links        *LinkMatcher
+	states       map[string]bool
+	gatewaysOnly bool
+
+	neighbors map[string]*neighbor
+}
+
+func (n *Network) watchNeighbors(c *conf.Config) {
+	w := &neighborWatcher{
+		links:        NewLinkMatcher(c.Neighbor.Links),
+		states:       make(map[string]bool),
+		gatewaysOnly: c.Neighbor.GatewaysOnly,
+		neighbors:    make(map[string]*neighbor),
+	}
+
+	for _, s := range strings.Fields(strings.ToLower(c.Neighbor.States)) {
+		w.states[s] = true
+	}
+
+	updates := make(chan netlink.NeighUpdate)
+	done := make(chan struct{}, MaxChannelSize)
+
+	if err := netlink.NeighSubscribeWithOptions(updates, done, netlink.NeighSubscribeOptions{
+		ErrorCallback: func(err error) {
+			log.Errorf("Received error from neighbor update subscription: %v", err)
+		},
+		ListExisting: true,
+	}); err != nil {
+		log.Errorf("Failed to subscribe neighbor update: %v", err)
+	}
+
+	for {
+		select {
+		case <-done:
+			log.Infoln("Neighbor watcher failed")
+		case updates, ok := <-updates:
+			if !ok {
+				break
+			}
+
+			n.updateNeighbor(w, updates)
+		}
+	}
+}
+
+// isKnownGateway reports whether ip is the gateway of a route configured on the link or
+// of a monitored gateway.
+func (n *Network) isKnownGateway(index int, ip net.IP) bool {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+
+	if rt, ok := n.RoutesByIndex[index]; ok && net.ParseIP(rt.Gw).Equal(ip) {
+		return true
+	}
+
+	if gs, ok := n.GatewaysByIndex[index]; ok && net.ParseIP(gs.Gw).Equal(ip) {
+		return true
+	}
+
+	return false
+}
+
+func (n *Network) updateNeighbor(w *neighborWatcher, update netlink.NeighUpdate) {
+	if update.IP == nil {
+		return
+	}
+
+	n.Mutex.Lock()
+	link := n.LinksByIndex[update.LinkIndex]
+	n.Mutex.Unlock()
+
+	if !w.links.Match(link) {
+		return
+	}
+
+	gateway := n.isKnownGateway(update.LinkIndex, update.IP)
+	if w.gatewaysOnly && !gateway {
+		return
+	}
+
+	key := strconv.Itoa(update.LinkIndex) + "/" + update.IP.String()
+	state := neighborState(update.State)
+	lladdr := update.HardwareAddr.String()
+
+	old, ok := w.neighbors[key]
+	if !ok {
+		old = &neighbor{}
+	}
+
+	if update.Type == syscall.RTM_DELNEIGH {
+		delete(w.neighbors, key)
+
+		executeNeighborScripts(NeighborEventRemoved, link, update.LinkIndex, update.IP, gateway, old, &neighbor{})
+		return
+	}
+
+	cur := &neighbor{state: state, lladdr: lladdr}
+
+	// Incomplete and failed entries carry no link layer address. Remember the last one seen
+	if cur.lladdr == "" {
+		cur.lladdr = old.lladdr
+	}
+	w.neighbors[key] = cur
+
+	if gateway && old.lladdr != "" && cur.lladdr != old.lladdr {
+		log.Warnf("Link layer address of gateway='%s' on link='%s' changed from '%s' to '%s'", update.IP, link, old.lladdr, cur.lladdr)
+
+		executeNeighborScripts(NeighborEventLLAddrChanged, link, update.LinkIndex, update.IP, gateway, old, cur)
+	}
+
+	// Neighbors seen for the first time are only reported when they failed
+	changed := ok && cur.state != old.state || !ok && update.State == netlink.NUD_FAILED
+	if changed && w.states[cur.state] {
+		log.Debugf("Neighbor='%s' on link='%s' changed state '%s' -> '%s'", update.IP, link, old.state, cur.state)
+
+		executeNeighborScripts(NeighborEventStateChanged, link, update.LinkIndex, update.IP, gateway, old, cur)
+	}
+}
+
+func executeNeighborScripts(event string, link string, index int, ip net.IP, gateway bool, old *neighbor, cur *neighbor) {
+	system.ExecuteScriptsInDir(conf.NeighborDir,
+		"EVENT="+event,
+		"LINK="+link,
+		"LINKINDEX="+strconv.Itoa(index),
+		"ADDRESS="+ip.String(),
+		"GATEWAY="+strconv.FormatBool(gateway),
+		"STATE="+cur.state,
+		"OLD_STATE="+old.state,
+		"LLADDR="+cur.lladdr,
+		"OLD_LLADDR="+old.lladdr,
+	)
+}
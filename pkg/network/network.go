@@ -8,29 +8,95 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/vmware/network-event-broker/pkg/conf"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/bus"
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/dns"
 )
 
 type Network struct {
 	LinksByName  map[string]int
 	LinksByIndex map[int]string
 
+	// Previous names of a link, oldest first
+	LinkRenames map[int][]string
+
+	TopologyByIndex       map[int]*Topology
+	LinkPropertiesByIndex map[int]*LinkProperties
+
+	AddressFlagsByKey map[string]int
+
 	RoutesByIndex             map[int]*Route
 	RoutingRulesByAddressFrom map[string]*RoutingRule
 	RoutingRulesByAddressTo   map[string]*RoutingRule
 
+	GatewaysByIndex map[int]*GatewayState
+
+	RoutableLinks map[int]bool
+	MultiPath     *MultiPath
+	Sysctl        *Sysctl
+
+	TrafficControl *TrafficControl
+	Masquerade     *Masquerade
+
+	// Clients of the system services sharing one connection to the system bus
+	Bus *bus.Clients
+
+	// Links whose DNS settings were pushed to the DNS backend and the hostname set from a link
+	DNS          dns.Backend
+	ResolveLinks map[int]bool
+
+	// Hostnames received on links, the policy choosing among them and the one set. Hostname
+	// is guarded by hostnameMutex instead of Mutex, which is not held across calls to hostnamed
+	HostnamesByIndex map[int]string
+	HostnamePolicy   *HostnamePolicy
+	Hostname         *HostnameState
+	hostnameMutex    sync.Mutex
+
+	// Links whose NTP servers were pushed to systemd-timesyncd
+	NTPLinks map[int]bool
+
+	RoutingPolicyMode  string
+	VRFsByIndex        map[int]*VRF
+	RoutingRulesByMark map[int]*RoutingRule
+	connmarkReady      bool
+
+	// Names the links were added to the nftables map 'link_marks' under
+	connmarkLinks map[int]string
+
 	Mutex *sync.Mutex
 }
 
 func New() *Network {
+	b := bus.NewClients(bus.NewConn())
+
 	return &Network{
+		Bus: b,
+
 		LinksByName:  make(map[string]int),
 		LinksByIndex: make(map[int]string),
+		LinkRenames:  make(map[int][]string),
+
+		TopologyByIndex:       make(map[int]*Topology),
+		LinkPropertiesByIndex: make(map[int]*LinkProperties),
+
+		AddressFlagsByKey: make(map[string]int),
 
 		RoutesByIndex:             make(map[int]*Route),
 		RoutingRulesByAddressFrom: make(map[string]*RoutingRule),
 		RoutingRulesByAddressTo:   make(map[string]*RoutingRule),
+		GatewaysByIndex:           make(map[int]*GatewayState),
+		RoutableLinks:             make(map[int]bool),
+		DNS:                       dns.NewResolved(b.Resolved),
+		ResolveLinks:              make(map[int]bool),
+		NTPLinks:                  make(map[int]bool),
+		HostnamesByIndex:          make(map[int]string),
+		HostnamePolicy:            &HostnamePolicy{Mode: HostnamePolicyTransient},
+		RoutingPolicyMode:         RoutingPolicyModeRules,
+		VRFsByIndex:               make(map[int]*VRF),
+		RoutingRulesByMark:        make(map[int]*RoutingRule),
+		connmarkLinks:             make(map[int]string),
 		Mutex:                     &sync.Mutex{},
 	}
 }
@@ -50,6 +116,10 @@ func ConfigureNetwork(link string, n *Network) error {
 		return err
 	}
 
+	if n.RoutingPolicyMode == RoutingPolicyModeVRF {
+		return n.configureVRF(link, index, gw)
+	}
+
 	rt := Route{
 		IfIndex: index,
 		Gw:      gw,
@@ -65,9 +135,13 @@ func ConfigureNetwork(link string, n *Network) error {
 
 	log.Debugf("Successfully added default gateway='%s' on link='%s' ifindex='%d' table='%d", gw, link, index, rt.Table)
 
+	if n.RoutingPolicyMode == RoutingPolicyModeFwMark {
+		return n.configureConnmark(link, index)
+	}
+
 	existingAddresses, err := getIPv4AddressesByLink(link)
 	if err != nil {
-		log.Errorf("Failed to fetch Ip addresses of link='%s' ifindex='%d': %+v", link, err)
+		log.Errorf("Failed to fetch Ip addresses of link='%s' ifindex='%d': %+v", link, index, err)
 		return err
 	}
 
@@ -134,3 +208,34 @@ func (n *Network) isRulesByTableEmpty(table int) bool {
 
 	return false
 }
+
+// dropLinkConfiguration removes the routes and routing policy rules installed for a link.
+// Callers must hold n.Mutex.
+func (n *Network) dropLinkConfiguration(ifIndex int) {
+	table := conf.ROUTE_TABLE_BASE + ifIndex
+
+	for address, rule := range n.RoutingRulesByAddressFrom {
+		if rule.Table == table {
+			rule.RoutingPolicyRuleRemove()
+			delete(n.RoutingRulesByAddressFrom, address)
+		}
+	}
+
+	for address, rule := range n.RoutingRulesByAddressTo {
+		if rule.Table == table {
+			rule.RoutingPolicyRuleRemove()
+			delete(n.RoutingRulesByAddressTo, address)
+		}
+	}
+
+	if rt, ok := n.RoutesByIndex[ifIndex]; ok {
+		log.Debugf("Dropping GW='%s' ifindex='%d' Table='%d'", rt.Gw, ifIndex, rt.Table)
+
+		// The kernel flushes the routes of removed links itself
+		rt.RouteRemove()
+		delete(n.RoutesByIndex, ifIndex)
+	}
+
+	n.dropVRF(ifIndex)
+	n.dropConnmark(ifIndex)
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	// All nftables objects managed by network-broker live in this table
+	NftTableFamily = "inet"
+	NftTableName   = "network_broker"
+)
+
+// nftApply loads the given commands atomically via nft(8).
+func nftApply(commands ...string) error {
+	script := strings.Join(commands, "\n") + "\n"
+
+	log.Debugf("Applying nftables commands: %s", script)
+
+	cmd := system.NetAdminCommand("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func nftObject(kind string, name string) string {
+	return kind + " " + NftTableFamily + " " + NftTableName + " " + name
+}
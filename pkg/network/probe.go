@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ProbeMethodARP  = "arp"
+	ProbeMethodICMP = "icmp"
+)
+
+// Prober checks whether a gateway reachable via a link answers.
+type Prober interface {
+	Probe(ifIndex int, gw net.IP, timeout time.Duration) error
+}
+
+func NewProber(method string) (Prober, error) {
+	switch method {
+	case ProbeMethodARP, "ndp", "":
+		return &neighborProber{}, nil
+	case ProbeMethodICMP:
+		return &icmpProber{}, nil
+	}
+
+	return nil, errors.New("unsupported probe method")
+}
+
+// neighborProber uses ARP for IPv4 gateways and NDP for IPv6 gateways.
+type neighborProber struct{}
+
+func (p *neighborProber) Probe(ifIndex int, gw net.IP, timeout time.Duration) error {
+	switch {
+	case gw.To4() != nil:
+		return arpProbe(ifIndex, gw.To4(), timeout)
+	case gw.To16() != nil:
+		return ndpProbe(ifIndex, gw, timeout)
+	}
+
+	return fmt.Errorf("invalid gateway address '%s'", gw)
+}
+
+type icmpProber struct{}
+
+func (p *icmpProber) Probe(ifIndex int, gw net.IP, timeout time.Duration) error {
+	return icmpProbe(ifIndex, gw, timeout)
+}
+
+func htons(v uint16) uint16 {
+	return (v << 8) | (v >> 8)
+}
+
+func linkSourceAddress(ifIndex int, family int) (net.IP, net.HardwareAddr, error) {
+	link, err := netlink.LinkByIndex(ifIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addrs, err := netlink.AddrList(link, family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, a := range addrs {
+		if family == netlink.FAMILY_V6 && !a.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		return a.IP, link.Attrs().HardwareAddr, nil
+	}
+
+	return nil, link.Attrs().HardwareAddr, errors.New("no source address")
+}
+
+func setReceiveTimeout(fd int, timeout time.Duration) error {
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	return unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+}
+
+func arpProbe(ifIndex int, gw net.IP, timeout time.Duration) error {
+	src, hw, err := linkSourceAddress(ifIndex, netlink.FAMILY_V4)
+	if err != nil {
+		return err
+	}
+
+	if len(hw) != 6 {
+		return errors.New("link has no ethernet address")
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	sa := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  ifIndex,
+		Halen:    6,
+	}
+	copy(sa.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	if err := unix.Bind(fd, sa); err != nil {
+		return err
+	}
+
+	if err := setReceiveTimeout(fd, timeout); err != nil {
+		return err
+	}
+
+	// ARP request: htype, ptype, hlen, plen, oper, sha, spa, tha, tpa
+	req := make([]byte, 28)
+	binary.BigEndian.PutUint16(req[0:2], 1)
+	binary.BigEndian.PutUint16(req[2:4], unix.ETH_P_IP)
+	req[4] = 6
+	req[5] = 4
+	binary.BigEndian.PutUint16(req[6:8], 1)
+	copy(req[8:14], hw)
+	copy(req[14:18], src.To4())
+	copy(req[24:28], gw)
+
+	if err := unix.Sendto(fd, req, 0, sa); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 128)
+	for time.Now().Before(deadline) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+
+		if n < 28 || binary.BigEndian.Uint16(buf[6:8]) != 2 {
+			continue
+		}
+
+		if net.IP(buf[14:18]).Equal(gw) {
+			return nil
+		}
+	}
+
+	return os.ErrDeadlineExceeded
+}
+
+func solicitedNodeMulticast(ip net.IP) net.IP {
+	m := net.ParseIP("ff02::1:ff00:0")
+	copy(m[13:], ip.To16()[13:])
+
+	return m
+}
+
+func ndpProbe(ifIndex int, gw net.IP, timeout time.Duration) error {
+	_, hw, _ := linkSourceAddress(ifIndex, netlink.FAMILY_V6)
+
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW, unix.IPPROTO_ICMPV6)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	// Neighbor discovery messages must be sent with hop limit 255
+	unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, 255)
+	unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, 255)
+	unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_IF, ifIndex)
+
+	if err := setReceiveTimeout(fd, timeout); err != nil {
+		return err
+	}
+
+	// Neighbor solicitation: type, code, checksum (filled by kernel), reserved, target, source link-layer address option
+	ns := make([]byte, 24)
+	ns[0] = 135
+	copy(ns[8:24], gw.To16())
+	if len(hw) == 6 {
+		ns = append(ns, 1, 1)
+		ns = append(ns, hw...)
+	}
+
+	dst := &unix.SockaddrInet6{ZoneId: uint32(ifIndex)}
+	copy(dst.Addr[:], solicitedNodeMulticast(gw))
+
+	if err := unix.Sendto(fd, ns, 0, dst); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+
+		// Neighbor advertisement
+		if n < 24 || buf[0] != 136 {
+			continue
+		}
+
+		if net.IP(buf[8:24]).Equal(gw) {
+			return nil
+		}
+	}
+
+	return os.ErrDeadlineExceeded
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+	}
+
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+
+	return ^uint16(sum)
+}
+
+func icmpProbe(ifIndex int, gw net.IP, timeout time.Duration) error {
+	link, err := netlink.LinkByIndex(ifIndex)
+	if err != nil {
+		return err
+	}
+
+	var fd int
+	var dst unix.Sockaddr
+	var echoRequest, echoReply byte
+
+	if gw.To4() != nil {
+		fd, err = unix.Socket(unix.AF_INET, unix.SOCK_RAW, unix.IPPROTO_ICMP)
+		sa := &unix.SockaddrInet4{}
+		copy(sa.Addr[:], gw.To4())
+		dst = sa
+		echoRequest, echoReply = 8, 0
+	} else {
+		fd, err = unix.Socket(unix.AF_INET6, unix.SOCK_RAW, unix.IPPROTO_ICMPV6)
+		sa := &unix.SockaddrInet6{ZoneId: uint32(ifIndex)}
+		copy(sa.Addr[:], gw.To16())
+		dst = sa
+		echoRequest, echoReply = 128, 129
+	}
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.BindToDevice(fd, link.Attrs().Name); err != nil {
+		return err
+	}
+
+	if err := setReceiveTimeout(fd, timeout); err != nil {
+		return err
+	}
+
+	id := uint16(os.Getpid() & 0xffff)
+	seq := uint16(time.Now().UnixNano() & 0xffff)
+
+	req := make([]byte, 16)
+	req[0] = echoRequest
+	binary.BigEndian.PutUint16(req[4:6], id)
+	binary.BigEndian.PutUint16(req[6:8], seq)
+	copy(req[8:], "nebprobe")
+
+	// The kernel computes the ICMPv6 checksum itself
+	if gw.To4() != nil {
+		binary.BigEndian.PutUint16(req[2:4], icmpChecksum(req))
+	}
+
+	if err := unix.Sendto(fd, req, 0, dst); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+
+		reply := buf[:n]
+
+		// IPv4 raw sockets deliver the IP header as well
+		if gw.To4() != nil {
+			if n < 20 {
+				continue
+			}
+			reply = reply[int(reply[0]&0x0f)*4:]
+		}
+
+		if len(reply) < 16 || reply[0] != echoReply {
+			continue
+		}
+
+		if binary.BigEndian.Uint16(reply[4:6]) == id && binary.BigEndian.Uint16(reply[6:8]) == seq && bytes.Equal(reply[8:16], req[8:16]) {
+			return nil
+		}
+	}
+
+	return os.ErrDeadlineExceeded
+}
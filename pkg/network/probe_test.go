@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netns"
+)
+
+const testProbeTimeout = 500 * time.Millisecond
+
+func TestNewProber(t *testing.T) {
+	tests := []struct {
+		method string
+		want   interface{}
+	}{
+		{"", &neighborProber{}},
+		{ProbeMethodARP, &neighborProber{}},
+		{"ndp", &neighborProber{}},
+		{ProbeMethodICMP, &icmpProber{}},
+		{"tcp", nil},
+	}
+
+	for _, tt := range tests {
+		p, err := NewProber(tt.method)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("NewProber('%s') succeeded, want error", tt.method)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("NewProber('%s') failed: %v", tt.method, err)
+			continue
+		}
+
+		switch tt.want.(type) {
+		case *neighborProber:
+			if _, ok := p.(*neighborProber); !ok {
+				t.Errorf("NewProber('%s')='%T', want neighbor prober", tt.method, p)
+			}
+		case *icmpProber:
+			if _, ok := p.(*icmpProber); !ok {
+				t.Errorf("NewProber('%s')='%T', want ICMP prober", tt.method, p)
+			}
+		}
+	}
+}
+
+func TestSolicitedNodeMulticast(t *testing.T) {
+	got := solicitedNodeMulticast(net.ParseIP("2001:db8::12:3456"))
+	if want := net.ParseIP("ff02::1:ff12:3456"); !got.Equal(want) {
+		t.Fatalf("solicitedNodeMulticast='%s', want '%s'", got, want)
+	}
+}
+
+func TestIcmpChecksum(t *testing.T) {
+	// Echo request of id 1 and sequence 1 without payload
+	b := []byte{8, 0, 0, 0, 0, 1, 0, 1}
+	if got := icmpChecksum(b); got != 0xf7fd {
+		t.Fatalf("icmpChecksum=%#04x, want 0xf7fd", got)
+	}
+}
+
+func TestProbeVeth(t *testing.T) {
+	withNetns(t, func(ns netns.NsHandle) {
+		v := newTestVeth(t, ns)
+
+		neighbor, _ := NewProber(ProbeMethodARP)
+		icmp, _ := NewProber(ProbeMethodICMP)
+
+		tests := []struct {
+			name    string
+			prober  Prober
+			gw      net.IP
+			answers bool
+		}{
+			{"arp", neighbor, v.PeerIPv4, true},
+			{"arp without neighbor", neighbor, net.ParseIP("192.0.2.99"), false},
+			{"ndp", neighbor, v.PeerIPv6, true},
+			{"ndp without neighbor", neighbor, net.ParseIP("2001:db8::99"), false},
+			{"icmp", icmp, v.PeerIPv4, true},
+			{"icmp without neighbor", icmp, net.ParseIP("192.0.2.99"), false},
+			{"icmpv6", icmp, v.PeerIPv6, true},
+		}
+
+		for _, tt := range tests {
+			err := tt.prober.Probe(v.Index, tt.gw, testProbeTimeout)
+			if tt.answers && err != nil {
+				t.Errorf("Probe %s of gateway='%s' failed: %v", tt.name, tt.gw, err)
+			} else if !tt.answers && err == nil {
+				t.Errorf("Probe %s of gateway='%s' succeeded, want no answer", tt.name, tt.gw)
+			}
+		}
+	})
+}
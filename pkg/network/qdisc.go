@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	QdiscFqCodel = "fq_codel"
+	QdiscTbf     = "tbf"
+	QdiscCake    = "cake"
+)
+
+// QdiscProfile is parsed from '<kind> [key=value|flag ...]', e.g. 'tbf rate=100mbit burst=32kb'.
+type QdiscProfile struct {
+	Spec   string
+	Kind   string
+	Params map[string]string
+	Flags  []string
+}
+
+func ParseQdiscProfile(spec string) (*QdiscProfile, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, errors.New("empty qdisc profile")
+	}
+
+	p := &QdiscProfile{
+		Spec:   spec,
+		Kind:   fields[0],
+		Params: make(map[string]string),
+	}
+
+	switch p.Kind {
+	case QdiscFqCodel, QdiscTbf, QdiscCake:
+	default:
+		return nil, fmt.Errorf("unsupported qdisc '%s'", p.Kind)
+	}
+
+	for _, t := range fields[1:] {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			p.Flags = append(p.Flags, t)
+			continue
+		}
+
+		p.Params[k] = v
+	}
+
+	if p.Kind == QdiscTbf && (p.Params["rate"] == "" || p.Params["burst"] == "") {
+		return nil, errors.New("tbf needs rate= and burst=")
+	}
+
+	return p, nil
+}
+
+// parseRate parses a tc(8) rate, e.g. '100mbit' or '10mbps', to bytes per second.
+func parseRate(s string) (uint64, error) {
+	units := []struct {
+		suffix string
+		bits   float64
+	}{
+		{"gbit", 1e9}, {"mbit", 1e6}, {"kbit", 1e3}, {"bit", 1},
+		{"gbps", 8e9}, {"mbps", 8e6}, {"kbps", 8e3}, {"bps", 8},
+	}
+
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(strings.ToLower(s), u.suffix); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, err
+			}
+
+			return uint64(f * u.bits / 8), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(f / 8), nil
+}
+
+// parseSize parses a tc(8) size, e.g. '32kb' or '1mb', to bytes.
+func parseSize(s string) (uint32, error) {
+	units := []struct {
+		suffix string
+		bytes  float64
+	}{
+		{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}, {"b", 1},
+	}
+
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(strings.ToLower(s), u.suffix); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, err
+			}
+
+			if f < 0 || f*u.bytes > math.MaxUint32 {
+				return 0, fmt.Errorf("size '%s' out of range", s)
+			}
+
+			return uint32(f * u.bytes), nil
+		}
+	}
+
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(v), nil
+}
+
+// netlinkQdisc builds the qdisc when the vendored netlink can express the profile and
+// returns nil otherwise.
+func (p *QdiscProfile) netlinkQdisc(index int) (netlink.Qdisc, error) {
+	attrs := netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    netlink.MakeHandle(1, 0),
+		Parent:    netlink.HANDLE_ROOT,
+	}
+
+	switch p.Kind {
+	case QdiscFqCodel:
+		q := netlink.NewFqCodel(attrs)
+
+		for _, f := range p.Flags {
+			switch f {
+			case "ecn":
+				q.ECN = 1
+			case "noecn":
+				q.ECN = 0
+			default:
+				return nil, nil
+			}
+		}
+
+		for k, v := range p.Params {
+			switch k {
+			case "limit", "flows", "quantum":
+				n, err := strconv.ParseUint(v, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s='%s': %w", k, v, err)
+				}
+
+				switch k {
+				case "limit":
+					q.Limit = uint32(n)
+				case "flows":
+					q.Flows = uint32(n)
+				case "quantum":
+					q.Quantum = uint32(n)
+				}
+			case "interval":
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid interval='%s': %w", v, err)
+				}
+
+				q.Interval = uint32(d.Microseconds())
+			default:
+				// e.g. target= is not sent by netlink
+				return nil, nil
+			}
+		}
+
+		return q, nil
+
+	case QdiscTbf:
+		if len(p.Flags) > 0 {
+			return nil, nil
+		}
+
+		rate, err := parseRate(p.Params["rate"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate='%s': %w", p.Params["rate"], err)
+		}
+
+		burst, err := parseSize(p.Params["burst"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst='%s': %w", p.Params["burst"], err)
+		}
+
+		q := &netlink.Tbf{
+			QdiscAttrs: attrs,
+			Rate:       rate,
+			Buffer:     uint32(netlink.Xmittime(rate, burst)),
+		}
+
+		for k, v := range p.Params {
+			switch k {
+			case "rate", "burst":
+			case "limit":
+				if q.Limit, err = parseSize(v); err != nil {
+					return nil, fmt.Errorf("invalid limit='%s': %w", v, err)
+				}
+			case "latency":
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid latency='%s': %w", v, err)
+				}
+
+				q.Limit = uint32(float64(rate)*d.Seconds()) + burst
+			default:
+				return nil, nil
+			}
+		}
+
+		// Like tc(8) with 'latency 50ms' when neither limit nor latency is given
+		if q.Limit == 0 {
+			q.Limit = uint32(float64(rate)*0.05) + burst
+		}
+
+		return q, nil
+	}
+
+	return nil, nil
+}
+
+// tcArgs returns the arguments of tc(8) replacing the root qdisc with the profile.
+func (p *QdiscProfile) tcArgs(link string) []string {
+	args := []string{"qdisc", "replace", "dev", link, "root", "handle", "1:", p.Kind}
+
+	keys := make([]string, 0, len(p.Params))
+	for k := range p.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		args = append(args, k, p.Params[k])
+	}
+
+	return append(args, p.Flags...)
+}
+
+func (p *QdiscProfile) apply(index int, link string) error {
+	q, err := p.netlinkQdisc(index)
+	if err != nil {
+		return err
+	}
+
+	if q != nil {
+		return netlink.QdiscReplace(q)
+	}
+
+	out, err := system.NetAdminCommand("tc", p.tcArgs(link)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tc: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// LinkQdisc returns the kind of the root qdisc of a link.
+func LinkQdisc(index int) (string, error) {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return "", err
+	}
+
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return "", err
+	}
+
+	for _, q := range qdiscs {
+		if q.Attrs().Parent == netlink.HANDLE_ROOT {
+			return q.Type(), nil
+		}
+	}
+
+	return "", nil
+}
+
+type qdiscLinkProfile struct {
+	links   *LinkMatcher
+	profile *QdiscProfile
+}
+
+// TrafficControl applies qdisc profiles to links and remembers where they were applied.
+type TrafficControl struct {
+	profiles []qdiscLinkProfile
+
+	mutex   sync.Mutex
+	applied map[int]*QdiscProfile
+}
+
+func NewTrafficControl(c *conf.Config) *TrafficControl {
+	tc := &TrafficControl{
+		applied: make(map[int]*QdiscProfile),
+	}
+
+	// Later profiles win, as in [Sysctl]
+	patterns := make([]string, 0, len(c.TrafficControl.Links))
+	for p := range c.TrafficControl.Links {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	for _, p := range patterns {
+		profile, err := ParseQdiscProfile(c.TrafficControl.Links[p])
+		if err != nil {
+			log.Warnf("Ignoring qdisc profile='%s' for links='%s': %v", c.TrafficControl.Links[p], p, err)
+			continue
+		}
+
+		tc.profiles = append(tc.profiles, qdiscLinkProfile{links: NewLinkMatcherFold(p), profile: profile})
+	}
+
+	return tc
+}
+
+func (tc *TrafficControl) match(link string) *QdiscProfile {
+	var profile *QdiscProfile
+	for _, p := range tc.profiles {
+		if p.links.Match(link) {
+			profile = p.profile
+		}
+	}
+
+	return profile
+}
+
+// ApplyQdisc replaces the root qdisc of the link with its profile unless a qdisc of the
+// same kind is already in place.
+func (n *Network) ApplyQdisc(index int, link string) error {
+	tc := n.TrafficControl
+	if tc == nil {
+		return nil
+	}
+
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	profile := tc.match(link)
+	if profile == nil {
+		delete(tc.applied, index)
+		return nil
+	}
+
+	kind, err := LinkQdisc(index)
+	if err != nil {
+		return err
+	}
+
+	_, ok := tc.applied[index]
+	if ok && kind == profile.Kind {
+		return nil
+	}
+
+	if err := profile.apply(index, link); err != nil {
+		log.Errorf("Failed to apply qdisc profile='%s' on link='%s' ifindex='%d': %v", profile.Spec, link, index, err)
+		return err
+	}
+
+	tc.applied[index] = profile
+
+	log.Infof("Applied qdisc profile='%s' on link='%s' ifindex='%d' (was '%s')", profile.Spec, link, index, kind)
+
+	return nil
+}
+
+// ForgetQdisc stops reconciling the qdisc of a link, e.g. when it has been removed.
+func (n *Network) ForgetQdisc(index int) {
+	if n.TrafficControl == nil {
+		return
+	}
+
+	n.TrafficControl.mutex.Lock()
+	defer n.TrafficControl.mutex.Unlock()
+
+	delete(n.TrafficControl.applied, index)
+}
+
+// WatchTrafficControl periodically reapplies the profiles of the links whose root qdisc
+// has been replaced or removed since.
+func WatchTrafficControl(n *Network, c *conf.Config) {
+	if c.TrafficControl.ReconcileInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.TrafficControl.ReconcileInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n.TrafficControl.mutex.Lock()
+		indexes := make([]int, 0, len(n.TrafficControl.applied))
+		for index := range n.TrafficControl.applied {
+			indexes = append(indexes, index)
+		}
+		n.TrafficControl.mutex.Unlock()
+
+		for _, index := range indexes {
+			n.Mutex.Lock()
+			link, ok := n.LinksByIndex[index]
+			n.Mutex.Unlock()
+			if !ok {
+				n.ForgetQdisc(index)
+				continue
+			}
+
+			n.ApplyQdisc(index, link)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQdiscProfile(t *testing.T) {
+	tests := []struct {
+		spec string
+		want *QdiscProfile
+	}{
+		{
+			"fq_codel",
+			&QdiscProfile{Spec: "fq_codel", Kind: QdiscFqCodel, Params: map[string]string{}},
+		},
+		{
+			"fq_codel limit=10240 ecn",
+			&QdiscProfile{Spec: "fq_codel limit=10240 ecn", Kind: QdiscFqCodel, Params: map[string]string{"limit": "10240"}, Flags: []string{"ecn"}},
+		},
+		{
+			"tbf rate=50mbit burst=64kb latency=40ms",
+			&QdiscProfile{Spec: "tbf rate=50mbit burst=64kb latency=40ms", Kind: QdiscTbf, Params: map[string]string{"rate": "50mbit", "burst": "64kb", "latency": "40ms"}},
+		},
+		{
+			"cake bandwidth=100mbit diffserv4 nat",
+			&QdiscProfile{Spec: "cake bandwidth=100mbit diffserv4 nat", Kind: QdiscCake, Params: map[string]string{"bandwidth": "100mbit"}, Flags: []string{"diffserv4", "nat"}},
+		},
+
+		{"", nil},
+		{"   ", nil},
+		{"htb rate=1mbit", nil},
+		{"tbf rate=50mbit", nil},
+		{"tbf burst=64kb", nil},
+		{"tbf rate= burst=64kb", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQdiscProfile(tt.spec)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseQdiscProfile('%s')=%+v, want error", tt.spec, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseQdiscProfile('%s') failed: %v", tt.spec, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQdiscProfile('%s')=%+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want uint64
+		ok   bool
+	}{
+		{"8000", 1000, true},
+		{"800bit", 100, true},
+		{"100kbit", 12500, true},
+		{"100mbit", 12500000, true},
+		{"1gbit", 125000000, true},
+		{"1.5Mbit", 187500, true},
+		{"100bps", 100, true},
+		{"10kbps", 10000, true},
+		{"10mbps", 10000000, true},
+		{"1gbps", 1000000000, true},
+
+		{"", 0, false},
+		{"mbit", 0, false},
+		{"fast", 0, false},
+		{"10mb", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRate(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseRate('%s')=%d,%v want %d,ok=%v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want uint32
+		ok   bool
+	}{
+		{"1514", 1514, true},
+		{"1514b", 1514, true},
+		{"32k", 32768, true},
+		{"32kb", 32768, true},
+		{"32KB", 32768, true},
+		{"1.5mb", 1572864, true},
+		{"1m", 1048576, true},
+		{"1g", 1073741824, true},
+
+		{"", 0, false},
+		{"kb", 0, false},
+		{"-1", 0, false},
+		{"4294967296", 0, false},
+		{"4gb", 0, false},
+		{"-1kb", 0, false},
+		{"big", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseSize('%s')=%d,%v want %d,ok=%v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/dns"
+)
+
+// SetResolveLink records that DNS settings of the link were pushed to the DNS backend.
+func (n *Network) SetResolveLink(index int) {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+
+	n.ResolveLinks[index] = true
+}
+
+// SetLinkNTP pushes the NTP servers of the link to systemd-timesyncd and records it.
+func (n *Network) SetLinkNTP(index int, servers []string) error {
+	if err := n.Bus.NTP.SetLinkNTPServers(context.Background(), index, servers); err != nil {
+		return err
+	}
+
+	n.Mutex.Lock()
+	n.NTPLinks[index] = true
+	link := n.LinksByIndex[index]
+	n.Mutex.Unlock()
+
+	log.Infof("Set NTP servers='%s' of link='%s' ifindex='%d'", strings.Join(servers, ","), link, index)
+
+	return nil
+}
+
+// RevertLink reverts the DNS and NTP settings pushed for the link and hands the hostname set
+// from it to the next link carrying one, or restores the previous hostname. When the link is
+// gone, resolved has already forgotten it and only the other DNS backends are asked to drop it.
+func (n *Network) RevertLink(index int, reason string, linkGone bool) {
+	n.Mutex.Lock()
+
+	link := n.LinksByIndex[index]
+
+	revertDNS := n.ResolveLinks[index]
+	delete(n.ResolveLinks, index)
+
+	revertNTP := n.NTPLinks[index]
+	delete(n.NTPLinks, index)
+
+	_, revertHostname := n.HostnamesByIndex[index]
+	delete(n.HostnamesByIndex, index)
+
+	n.Mutex.Unlock()
+
+	if revertDNS && (!linkGone || n.DNS.Name() != dns.BackendResolved) {
+		log.Infof("Reverting DNS settings of link='%s' ifindex='%d': %s", link, index, reason)
+
+		if err := n.DNS.RevertLink(index); err != nil {
+			log.Warnln(err)
+		}
+	}
+
+	if revertNTP {
+		log.Infof("Reverting NTP servers of link='%s' ifindex='%d': %s", link, index, reason)
+
+		if err := n.Bus.NTP.RevertLinkNTPServers(context.Background(), index); err != nil {
+			if linkGone {
+				log.Debugln(err)
+			} else {
+				log.Warnln(err)
+			}
+		}
+	}
+
+	if revertHostname {
+		if err := n.updateHostname(reason); err != nil {
+			log.Warnln(err)
+		}
+	}
+}
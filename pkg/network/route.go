@@ -60,7 +60,8 @@ func GetDefaultIpv4GatewayByLink(ifIndex int) (string, error) {
 	}
 
 	for _, route := range routes {
-		if route.LinkIndex == ifIndex {
+		// Default routes without a gateway such as 'default dev wg0' have nothing to return
+		if route.LinkIndex == ifIndex && route.Gw != nil {
 			if route.Dst == nil || route.Dst.String() == "0.0.0.0/0" {
 				return route.Gw.To4().String(), nil
 			}
@@ -85,6 +86,7 @@ func GetIpv4Gateway(ifIndex int) (string, error) {
 
 	return gw, nil
 }
+
 func (route *Route) RouteAdd() error {
 	rt := netlink.Route{
 		LinkIndex: route.IfIndex,
@@ -112,3 +114,20 @@ func (route *Route) RouteRemove() error {
 
 	return nil
 }
+
+func GetIpv6GatewayByLink(ifIndex int) (string, error) {
+	routes, err := netlink.RouteList(nil, syscall.AF_INET6)
+	if err != nil {
+		return "", err
+	}
+
+	for _, route := range routes {
+		if route.LinkIndex == ifIndex && route.Gw != nil {
+			if route.Dst == nil || route.Dst.String() == "::/0" {
+				return route.Gw.String(), nil
+			}
+		}
+	}
+
+	return "", errors.New("not found")
+}
@@ -12,6 +12,7 @@ import (
 type RoutingRule struct {
 	From  string
 	To    string
+	Mark  int
 	Table int
 }
 
@@ -42,6 +43,10 @@ func (rule *RoutingRule) RoutingPolicyRuleAdd() error {
 		r.Dst = &net.IPNet{IP: net.ParseIP(rule.To), Mask: net.CIDRMask(32, 32)}
 	}
 
+	if rule.Mark > 0 {
+		r.Mark = rule.Mark
+	}
+
 	// find this rule
 	found := ruleExists(rules, *r)
 	if found {
@@ -67,6 +72,10 @@ func (rule *RoutingRule) RoutingPolicyRuleRemove() error {
 		r.Dst = &net.IPNet{IP: net.ParseIP(rule.To), Mask: net.CIDRMask(32, 32)}
 	}
 
+	if rule.Mark > 0 {
+		r.Mark = rule.Mark
+	}
+
 	if err := netlink.RuleDel(r); err != nil {
 		return err
 	}
@@ -90,6 +99,7 @@ func ruleEquals(a, b netlink.Rule) bool {
 			(a.Src != nil && b.Src != nil && a.Src.String() == b.Src.String())) &&
 		((a.Dst == nil && b.Dst == nil) ||
 			(a.Dst != nil && b.Dst != nil && a.Dst.String() == b.Dst.String())) &&
+		a.Mark == b.Mark &&
 		a.OifName == b.OifName &&
 		a.IifName == b.IifName
 }
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+const (
+	StatisticsAlarmRaised  = "raised"
+	StatisticsAlarmCleared = "cleared"
+)
+
+// StatisticsThreshold is parsed from 'Counter>value' (per second) or 'Counter>value%'
+// (per cent of the packets received or transmitted in the same interval).
+type StatisticsThreshold struct {
+	Spec    string
+	Counter string
+	Value   float64
+	Percent bool
+}
+
+func ParseStatisticsThresholds(s string) []*StatisticsThreshold {
+	var thresholds []*StatisticsThreshold
+
+	stats := reflect.TypeOf(netlink.LinkStatistics{})
+
+	for _, spec := range strings.Fields(s) {
+		counter, value, ok := strings.Cut(spec, ">")
+		if !ok {
+			log.Warnf("Ignoring invalid statistics threshold='%s'", spec)
+			continue
+		}
+
+		if _, ok := stats.FieldByName(counter); !ok {
+			log.Warnf("Ignoring statistics threshold='%s': unknown counter '%s'", spec, counter)
+			continue
+		}
+
+		t := &StatisticsThreshold{
+			Spec:    spec,
+			Counter: counter,
+			Percent: strings.HasSuffix(value, "%"),
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			log.Warnf("Ignoring invalid statistics threshold='%s': %v", spec, err)
+			continue
+		}
+		t.Value = v
+
+		thresholds = append(thresholds, t)
+	}
+
+	return thresholds
+}
+
+func statisticsCounter(s *netlink.LinkStatistics, counter string) uint64 {
+	return reflect.ValueOf(s).Elem().FieldByName(counter).Uint()
+}
+
+type statisticsSample struct {
+	at    time.Time
+	stats netlink.LinkStatistics
+}
+
+// rate returns the rate of the counter of the threshold between two samples. It reports false
+// when a counter went backwards, as it does when the driver resets its statistics.
+func (t *StatisticsThreshold) rate(prev *statisticsSample, cur *statisticsSample) (float64, bool) {
+	c, p := statisticsCounter(&cur.stats, t.Counter), statisticsCounter(&prev.stats, t.Counter)
+	if c < p || cur.stats.RxPackets < prev.stats.RxPackets || cur.stats.TxPackets < prev.stats.TxPackets {
+		return 0, false
+	}
+
+	delta := float64(c - p)
+
+	if !t.Percent {
+		return delta / cur.at.Sub(prev.at).Seconds(), true
+	}
+
+	var packets float64
+	switch {
+	case strings.HasPrefix(t.Counter, "Rx"):
+		packets = float64(cur.stats.RxPackets - prev.stats.RxPackets)
+	case strings.HasPrefix(t.Counter, "Tx"):
+		packets = float64(cur.stats.TxPackets - prev.stats.TxPackets)
+	default:
+		packets = float64(cur.stats.RxPackets - prev.stats.RxPackets + cur.stats.TxPackets - prev.stats.TxPackets)
+	}
+
+	if packets == 0 {
+		return 0, true
+	}
+
+	return delta * 100 / packets, true
+}
+
+// WatchStatistics samples the statistics of the matching links and executes the scripts in
+// stats-alarm.d when a threshold is crossed and when the rate recovers.
+func WatchStatistics(c *conf.Config) {
+	links := NewLinkMatcher(c.Statistics.Links)
+	thresholds := ParseStatisticsThresholds(c.Statistics.Thresholds)
+	if len(thresholds) == 0 {
+		log.Warnln("No valid statistics thresholds configured, not sampling link statistics")
+		return
+	}
+
+	log.Infof("Sampling link statistics of links='%s' interval='%v'", c.Statistics.Links, c.Statistics.Interval)
+
+	samples := make(map[int]*statisticsSample)
+	raised := make(map[string]bool)
+
+	ticker := time.NewTicker(c.Statistics.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		linkList, err := netlink.LinkList()
+		if err != nil {
+			log.Errorf("Failed to acquire link statistics: %v", err)
+			continue
+		}
+
+		seen := make(map[int]bool)
+		for _, link := range linkList {
+			attrs := link.Attrs()
+			if attrs.Statistics == nil || !links.MatchLink(link) {
+				continue
+			}
+
+			seen[attrs.Index] = true
+
+			cur := &statisticsSample{at: time.Now(), stats: *attrs.Statistics}
+			prev, ok := samples[attrs.Index]
+			samples[attrs.Index] = cur
+			if !ok {
+				continue
+			}
+
+			for _, t := range thresholds {
+				key := strconv.Itoa(attrs.Index) + "/" + t.Spec
+				rate, ok := t.rate(prev, cur)
+				if !ok {
+					log.Debugf("Link='%s' ifindex='%d' statistics were reset, skipping sample of '%s'", attrs.Name, attrs.Index, t.Spec)
+					continue
+				}
+
+				switch {
+				case rate > t.Value && !raised[key]:
+					raised[key] = true
+
+					log.Warnf("Link='%s' ifindex='%d' statistics alarm '%s' raised rate='%.2f'", attrs.Name, attrs.Index, t.Spec, rate)
+					executeStatisticsScripts(StatisticsAlarmRaised, attrs, t, rate)
+
+				case rate <= t.Value && raised[key]:
+					delete(raised, key)
+
+					log.Infof("Link='%s' ifindex='%d' statistics alarm '%s' cleared rate='%.2f'", attrs.Name, attrs.Index, t.Spec, rate)
+					executeStatisticsScripts(StatisticsAlarmCleared, attrs, t, rate)
+				}
+			}
+		}
+
+		// Forget links which are gone
+		for index := range samples {
+			if !seen[index] {
+				delete(samples, index)
+
+				for _, t := range thresholds {
+					delete(raised, strconv.Itoa(index)+"/"+t.Spec)
+				}
+			}
+		}
+	}
+}
+
+func executeStatisticsScripts(alarm string, attrs *netlink.LinkAttrs, t *StatisticsThreshold, rate float64) {
+	unit := "/s"
+	if t.Percent {
+		unit = "%"
+	}
+
+	system.ExecuteScriptsInDir(conf.StatisticsAlarmDir,
+		"ALARM="+alarm,
+		"LINK="+attrs.Name,
+		"LINKINDEX="+strconv.Itoa(attrs.Index),
+		"COUNTER="+t.Counter,
+		"THRESHOLD="+t.Spec,
+		"RATE="+strconv.FormatFloat(rate, 'f', 2, 64)+unit,
+	)
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestParseStatisticsThresholds(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []*StatisticsThreshold
+	}{
+		{"", nil},
+		{"RxErrors>10", []*StatisticsThreshold{{Spec: "RxErrors>10", Counter: "RxErrors", Value: 10}}},
+		{"TxDropped>0.5%", []*StatisticsThreshold{{Spec: "TxDropped>0.5%", Counter: "TxDropped", Value: 0.5, Percent: true}}},
+		{
+			"RxErrors>10 Collisions>1%",
+			[]*StatisticsThreshold{
+				{Spec: "RxErrors>10", Counter: "RxErrors", Value: 10},
+				{Spec: "Collisions>1%", Counter: "Collisions", Value: 1, Percent: true},
+			},
+		},
+
+		// Invalid thresholds are skipped, the valid ones kept
+		{"RxErrors", nil},
+		{"RxErrors<10", nil},
+		{"Unknown>10", nil},
+		{"rxerrors>10", nil},
+		{"RxErrors>ten", nil},
+		{"RxErrors>%", nil},
+		{"RxErrors> TxErrors>5", []*StatisticsThreshold{{Spec: "TxErrors>5", Counter: "TxErrors", Value: 5}}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseStatisticsThresholds(tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseStatisticsThresholds('%s')=%+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestStatisticsThresholdRate(t *testing.T) {
+	at := time.Unix(1000, 0)
+
+	sample := func(seconds int, rxPackets, txPackets, rxErrors uint64) *statisticsSample {
+		return &statisticsSample{
+			at: at.Add(time.Duration(seconds) * time.Second),
+			stats: netlink.LinkStatistics{
+				RxPackets: rxPackets,
+				TxPackets: txPackets,
+				RxErrors:  rxErrors,
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		threshold string
+		prev, cur *statisticsSample
+		rate      float64
+		ok        bool
+	}{
+		{"per second", "RxErrors>1", sample(0, 0, 0, 10), sample(10, 0, 0, 60), 5, true},
+		{"per cent of received", "RxErrors>1%", sample(0, 100, 0, 0), sample(10, 300, 500, 4), 2, true},
+		{"no packets", "RxErrors>1%", sample(0, 100, 0, 0), sample(10, 100, 0, 4), 0, true},
+		{"counter reset", "RxErrors>1", sample(0, 0, 0, 60), sample(10, 0, 0, 5), 0, false},
+		{"packet counter reset", "RxErrors>1%", sample(0, 500, 0, 0), sample(10, 20, 0, 1), 0, false},
+	}
+
+	for _, tt := range tests {
+		th := ParseStatisticsThresholds(tt.threshold)[0]
+
+		rate, ok := th.rate(tt.prev, tt.cur)
+		if rate != tt.rate || ok != tt.ok {
+			t.Errorf("%s: rate of '%s'=%v,%v want %v,%v", tt.name, tt.threshold, rate, ok, tt.rate, tt.ok)
+		}
+	}
+}
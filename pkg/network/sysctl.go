@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const procSysNet = "/proc/sys/net"
+
+// SysctlSetting is parsed from '<family>.<key>=<value>', e.g. 'ipv4.rp_filter=2', and
+// applies to net.<family>.conf.<link>.<key>.
+type SysctlSetting struct {
+	Family string
+	Key    string
+	Value  string
+}
+
+func (s *SysctlSetting) path(link string) string {
+	return path.Join(procSysNet, s.Family, "conf", link, s.Key)
+}
+
+func (s *SysctlSetting) name(link string) string {
+	return "net." + s.Family + ".conf." + link + "." + s.Key
+}
+
+type sysctlProfile struct {
+	links    *LinkMatcher
+	settings []SysctlSetting
+}
+
+// Sysctl applies per link sysctl settings and remembers the original values.
+type Sysctl struct {
+	profiles []sysctlProfile
+	restore  bool
+
+	mutex sync.Mutex
+	// Original values by ifindex and '<family>/<key>'
+	original map[int]map[string]*SysctlSetting
+}
+
+func NewSysctl(c *conf.Config) *Sysctl {
+	s := &Sysctl{
+		restore:  c.Sysctl.RestoreOnRemoval,
+		original: make(map[int]map[string]*SysctlSetting),
+	}
+
+	// Apply profiles in a stable order, later profiles win
+	patterns := make([]string, 0, len(c.Sysctl.Links))
+	for p := range c.Sysctl.Links {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	for _, p := range patterns {
+		profile := sysctlProfile{links: NewLinkMatcherFold(p)}
+
+		for _, t := range strings.Fields(c.Sysctl.Links[p]) {
+			k, v, ok := strings.Cut(t, "=")
+			family, key, ok2 := strings.Cut(k, ".")
+			if !ok || !ok2 || (family != "ipv4" && family != "ipv6") || strings.ContainsAny(key, "/.") {
+				log.Warnf("Ignoring invalid sysctl setting='%s' for links='%s'", t, p)
+				continue
+			}
+
+			profile.settings = append(profile.settings, SysctlSetting{Family: family, Key: key, Value: v})
+		}
+
+		s.profiles = append(s.profiles, profile)
+	}
+
+	return s
+}
+
+func readSysctl(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+func writeSysctl(path string, value string) error {
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
+// ApplySysctls writes the settings of all profiles matching the link. Settings applied before
+// which no longer match, e.g. after a rename, are restored. Failures are reported per key.
+func (n *Network) ApplySysctls(index int, link string) error {
+	s := n.Sysctl
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Later profiles override earlier ones
+	settings := make(map[string]SysctlSetting)
+	for _, profile := range s.profiles {
+		if !profile.links.Match(link) {
+			continue
+		}
+
+		for _, setting := range profile.settings {
+			settings[setting.Family+"/"+setting.Key] = setting
+		}
+	}
+
+	if _, ok := s.original[index]; !ok {
+		s.original[index] = make(map[string]*SysctlSetting)
+	}
+	original := s.original[index]
+
+	var errs []error
+	for k, o := range original {
+		if _, ok := settings[k]; ok {
+			continue
+		}
+
+		delete(original, k)
+		if err := s.restoreSetting(link, o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for k, setting := range settings {
+		cur, err := readSysctl(setting.path(link))
+		if err != nil {
+			log.Warnf("Failed to read sysctl '%s': %v", setting.name(link), err)
+			errs = append(errs, fmt.Errorf("%s: %w", setting.name(link), err))
+			continue
+		}
+
+		if _, ok := original[k]; !ok {
+			original[k] = &SysctlSetting{Family: setting.Family, Key: setting.Key, Value: cur}
+		}
+
+		if cur == setting.Value {
+			continue
+		}
+
+		if err := writeSysctl(setting.path(link), setting.Value); err != nil {
+			log.Warnf("Failed to set sysctl '%s'='%s': %v", setting.name(link), setting.Value, err)
+			errs = append(errs, fmt.Errorf("%s: %w", setting.name(link), err))
+			continue
+		}
+
+		log.Debugf("Set sysctl '%s'='%s' (was '%s')", setting.name(link), setting.Value, cur)
+	}
+
+	if len(original) == 0 {
+		delete(s.original, index)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ApplyAllSysctls applies the settings to all links known at startup.
+func (n *Network) ApplyAllSysctls() {
+	n.Mutex.Lock()
+	links := make(map[int]string, len(n.LinksByIndex))
+	for index, link := range n.LinksByIndex {
+		links[index] = link
+	}
+	n.Mutex.Unlock()
+
+	for index, link := range links {
+		n.ApplySysctls(index, link)
+	}
+}
+
+// restoreSetting writes back an original value when RestoreOnRemoval= is set.
+// Callers must hold s.mutex.
+func (s *Sysctl) restoreSetting(link string, o *SysctlSetting) error {
+	if !s.restore {
+		return nil
+	}
+
+	// The entries of removed links are gone with them
+	if _, err := os.Stat(o.path(link)); err != nil {
+		return nil
+	}
+
+	if err := writeSysctl(o.path(link), o.Value); err != nil {
+		log.Warnf("Failed to restore sysctl '%s'='%s': %v", o.name(link), o.Value, err)
+		return fmt.Errorf("%s: %w", o.name(link), err)
+	}
+
+	log.Debugf("Restored sysctl '%s'='%s'", o.name(link), o.Value)
+
+	return nil
+}
+
+// RestoreSysctls writes back the original values of the settings applied to the link
+// when RestoreOnRemoval= is set and forgets them.
+func (n *Network) RestoreSysctls(index int, link string) error {
+	s := n.Sysctl
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	original, ok := s.original[index]
+	if !ok {
+		return nil
+	}
+	delete(s.original, index)
+
+	var errs []error
+	for _, o := range original {
+		if err := s.restoreSetting(link, o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RestoreAllSysctls restores the settings of all links, e.g. when the daemon stops.
+func (n *Network) RestoreAllSysctls() {
+	if n.Sysctl == nil {
+		return
+	}
+
+	n.Sysctl.mutex.Lock()
+	indexes := make([]int, 0, len(n.Sysctl.original))
+	for index := range n.Sysctl.original {
+		indexes = append(indexes, index)
+	}
+	n.Sysctl.mutex.Unlock()
+
+	for _, index := range indexes {
+		n.Mutex.Lock()
+		link := n.LinksByIndex[index]
+		n.Mutex.Unlock()
+
+		n.RestoreSysctls(index, link)
+	}
+}
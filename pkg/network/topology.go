@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+// Topology describes how a link relates to other links.
+type Topology struct {
+	Kind        string
+	MasterIndex int
+	ParentIndex int
+	ActiveSlave int
+	BondMode    string
+}
+
+func linkTopology(link netlink.Link) *Topology {
+	t := &Topology{
+		Kind:        link.Type(),
+		MasterIndex: link.Attrs().MasterIndex,
+		ParentIndex: link.Attrs().ParentIndex,
+		ActiveSlave: -1,
+	}
+
+	if bond, ok := link.(*netlink.Bond); ok {
+		t.ActiveSlave = bond.ActiveSlave
+		t.BondMode = bond.Mode.String()
+	}
+
+	return t
+}
+
+func linkNameByIndex(index int) string {
+	l, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+
+	return l.Attrs().Name
+}
+
+// updateTopology compares the master, parent and active slave of a link with the previous
+// update and executes the topology scripts.
+func (n *Network) updateTopology(link netlink.Link, isNew bool) {
+	index := link.Attrs().Index
+	cur := linkTopology(link)
+
+	n.Mutex.Lock()
+	old, ok := n.TopologyByIndex[index]
+	n.TopologyByIndex[index] = cur
+	n.Mutex.Unlock()
+
+	if !ok {
+		old = &Topology{ActiveSlave: -1}
+	}
+
+	if isNew && cur.ParentIndex > 0 && cur.Kind == "vlan" {
+		log.Infof("VLAN link='%s' ifindex='%d' created on parent ifindex='%d'", link.Attrs().Name, index, cur.ParentIndex)
+
+		env := topologyEnv(link, cur)
+		if vlan, ok := link.(*netlink.Vlan); ok {
+			env = append(env, "VLAN_ID="+strconv.Itoa(vlan.VlanId))
+		}
+
+		system.ExecuteScriptsInDir(conf.VLANCreatedDir, env...)
+	}
+
+	if !isNew && !ok {
+		return
+	}
+
+	if old.MasterIndex != cur.MasterIndex {
+		if old.MasterIndex > 0 {
+			linkReleased(link, old)
+		}
+
+		if cur.MasterIndex > 0 {
+			log.Infof("Link='%s' ifindex='%d' enslaved to master ifindex='%d'", link.Attrs().Name, index, cur.MasterIndex)
+
+			system.ExecuteScriptsInDir(conf.EnslavedDir, topologyEnv(link, cur)...)
+		}
+	}
+
+	if cur.Kind == "bond" && ok && old.ActiveSlave != cur.ActiveSlave {
+		log.Infof("Bond='%s' ifindex='%d' active slave changed from ifindex='%d' to ifindex='%d'", link.Attrs().Name, index, old.ActiveSlave, cur.ActiveSlave)
+
+		env := append(topologyEnv(link, cur),
+			"ACTIVE_SLAVE="+linkNameByIndex(cur.ActiveSlave),
+			"OLD_ACTIVE_SLAVE="+linkNameByIndex(old.ActiveSlave),
+		)
+
+		system.ExecuteScriptsInDir(conf.ActiveSlaveChangedDir, env...)
+	}
+}
+
+// removeTopology forgets the topology of a removed link. A link removed while enslaved, e.g.
+// one end of a veth pair, is released from its master first.
+func (n *Network) removeTopology(link netlink.Link) {
+	index := link.Attrs().Index
+
+	n.Mutex.Lock()
+	old, ok := n.TopologyByIndex[index]
+	delete(n.TopologyByIndex, index)
+	n.Mutex.Unlock()
+
+	if ok && old.MasterIndex > 0 {
+		linkReleased(link, old)
+	}
+}
+
+func linkReleased(link netlink.Link, old *Topology) {
+	log.Infof("Link='%s' ifindex='%d' released from master ifindex='%d'", link.Attrs().Name, link.Attrs().Index, old.MasterIndex)
+
+	system.ExecuteScriptsInDir(conf.ReleasedDir, topologyEnv(link, old)...)
+}
+
+func topologyEnv(link netlink.Link, t *Topology) []string {
+	env := []string{
+		"LINK=" + link.Attrs().Name,
+		"LINKINDEX=" + strconv.Itoa(link.Attrs().Index),
+		"KIND=" + t.Kind,
+	}
+
+	if t.MasterIndex > 0 {
+		env = append(env, "MASTER="+linkNameByIndex(t.MasterIndex), "MASTERINDEX="+strconv.Itoa(t.MasterIndex))
+
+		if m, err := netlink.LinkByIndex(t.MasterIndex); err == nil {
+			env = append(env, "MASTER_KIND="+m.Type())
+
+			if bond, ok := m.(*netlink.Bond); ok {
+				env = append(env, "BOND_MODE="+bond.Mode.String())
+			}
+		}
+	}
+
+	if t.ParentIndex > 0 {
+		env = append(env, "PARENT="+linkNameByIndex(t.ParentIndex), "PARENTINDEX="+strconv.Itoa(t.ParentIndex))
+	}
+
+	if t.BondMode != "" {
+		env = append(env, "BOND_MODE="+t.BondMode)
+	}
+
+	if slave, ok := link.Attrs().Slave.(*netlink.BondSlave); ok {
+		env = append(env, "SLAVE_STATE="+slave.State.String())
+	}
+
+	return env
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+	"github.com/vmware/network-event-broker/pkg/system"
+)
+
+func TestRemoveTopology(t *testing.T) {
+	var released []string
+	remove := system.AddEventHook(func(dir string, env []string) {
+		if dir == conf.ReleasedDir {
+			released = append(released, env[0])
+		}
+	})
+	t.Cleanup(remove)
+
+	n := New()
+	n.TopologyByIndex[5] = &Topology{Kind: "veth", MasterIndex: 3, ActiveSlave: -1}
+	n.TopologyByIndex[6] = &Topology{Kind: "veth", ActiveSlave: -1}
+
+	// Removing an enslaved link releases it, others just go
+	for _, index := range []int{5, 6, 7} {
+		n.removeTopology(&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "veth" + strconv.Itoa(index), Index: index}})
+	}
+
+	if len(released) != 1 || released[0] != "LINK=veth5" {
+		t.Errorf("Released links='%v', want 'LINK=veth5'", released)
+	}
+
+	if len(n.TopologyByIndex) != 0 {
+		t.Errorf("Topologies left after removing the links: %+v", n.TopologyByIndex)
+	}
+}
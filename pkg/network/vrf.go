@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"errors"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/vmware/network-event-broker/pkg/conf"
+)
+
+const (
+	RoutingPolicyModeRules = "rules"
+	RoutingPolicyModeVRF   = "vrf"
+
+	vrfNamePrefix = "vrf-"
+)
+
+// ParseRoutingPolicyMode returns the mode of RoutingPolicyMode=, falling back to rules for
+// unknown ones.
+func ParseRoutingPolicyMode(mode string) string {
+	switch mode {
+	case RoutingPolicyModeRules, RoutingPolicyModeVRF, RoutingPolicyModeFwMark:
+		return mode
+	}
+
+	log.Warnf("Unknown RoutingPolicyMode='%s', falling back to '%s'", mode, RoutingPolicyModeRules)
+
+	return RoutingPolicyModeRules
+}
+
+type VRF struct {
+	Name    string
+	Index   int
+	Table   int
+	Created bool
+}
+
+// vrfName derives the name of the VRF from the ifindex of the link, which unlike the link name
+// is unique and always fits IFNAMSIZ.
+func vrfName(index int) string {
+	return vrfNamePrefix + strconv.Itoa(index)
+}
+
+// LinkVRF returns the name of the VRF the link is enslaved to.
+func LinkVRF(link string) (string, error) {
+	l, err := netlink.LinkByName(link)
+	if err != nil {
+		return "", err
+	}
+
+	if l.Attrs().MasterIndex == 0 {
+		return "", errors.New("not found")
+	}
+
+	m, err := netlink.LinkByIndex(l.Attrs().MasterIndex)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := m.(*netlink.Vrf); !ok {
+		return "", errors.New("not found")
+	}
+
+	return m.Attrs().Name, nil
+}
+
+func acquireVRF(link netlink.Link, index int) (*VRF, error) {
+	// Use the VRF the link is already enslaved to
+	if link.Attrs().MasterIndex > 0 {
+		m, err := netlink.LinkByIndex(link.Attrs().MasterIndex)
+		if err == nil {
+			if v, ok := m.(*netlink.Vrf); ok {
+				return &VRF{Name: v.Name, Index: v.Index, Table: int(v.Table)}, nil
+			}
+		}
+	}
+
+	name := vrfName(index)
+
+	m, err := netlink.LinkByName(name)
+	if err == nil {
+		v, ok := m.(*netlink.Vrf)
+		if !ok {
+			return nil, errors.New("link '" + name + "' exists and is not a VRF")
+		}
+
+		return &VRF{Name: v.Name, Index: v.Index, Table: int(v.Table)}, nil
+	}
+
+	v := &netlink.Vrf{
+		LinkAttrs: netlink.LinkAttrs{Name: name},
+		Table:     uint32(conf.ROUTE_TABLE_BASE + index),
+	}
+
+	if err := netlink.LinkAdd(v); err != nil {
+		return nil, err
+	}
+
+	m, err = netlink.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created VRF='%s' table='%d'", name, v.Table)
+
+	return &VRF{Name: name, Index: m.Attrs().Index, Table: int(v.Table), Created: true}, nil
+}
+
+// configureVRF enslaves the link to its VRF and installs the default route in the table of the VRF.
+// Callers must hold n.Mutex.
+func (n *Network) configureVRF(link string, index int, gw string) error {
+	l, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return err
+	}
+
+	vrf, ok := n.VRFsByIndex[index]
+	if !ok {
+		vrf, err = acquireVRF(l, index)
+		if err != nil {
+			log.Warnf("Failed to acquire VRF for link='%s' ifindex='%d': %v", link, index, err)
+			return err
+		}
+
+		n.VRFsByIndex[index] = vrf
+	}
+
+	m, err := netlink.LinkByIndex(vrf.Index)
+	if err != nil {
+		return err
+	}
+
+	if err := netlink.LinkSetUp(m); err != nil {
+		return err
+	}
+
+	if l.Attrs().MasterIndex != vrf.Index {
+		if err := netlink.LinkSetMasterByIndex(l, vrf.Index); err != nil {
+			log.Warnf("Failed to enslave link='%s' ifindex='%d' to VRF='%s': %v", link, index, vrf.Name, err)
+			return err
+		}
+	}
+
+	rt := Route{
+		IfIndex: index,
+		Gw:      gw,
+		Table:   vrf.Table,
+	}
+
+	if err = rt.RouteAdd(); err != nil {
+		log.Warnf("Failed to add default gateway on link='%s' ifindex='%d' gw='%s' VRF='%s' table='%d': %+v", link, index, gw, vrf.Name, rt.Table, err)
+		return err
+	}
+
+	n.RoutesByIndex[index] = &rt
+
+	log.Debugf("Successfully added default gateway='%s' on link='%s' ifindex='%d' VRF='%s' table='%d'", gw, link, index, vrf.Name, rt.Table)
+
+	return nil
+}
+
+// dropVRF releases the link from its VRF and removes the VRF when it was created by us.
+// Callers must hold n.Mutex.
+func (n *Network) dropVRF(index int) {
+	vrf, ok := n.VRFsByIndex[index]
+	if !ok {
+		return
+	}
+	delete(n.VRFsByIndex, index)
+
+	if l, err := netlink.LinkByIndex(index); err == nil && l.Attrs().MasterIndex == vrf.Index {
+		netlink.LinkSetNoMaster(l)
+	}
+
+	if !vrf.Created {
+		return
+	}
+
+	if m, err := netlink.LinkByIndex(vrf.Index); err == nil {
+		log.Debugf("Removing VRF='%s' table='%d'", vrf.Name, vrf.Table)
+
+		if err := netlink.LinkDel(m); err != nil {
+			log.Warnf("Failed to remove VRF='%s': %v", vrf.Name, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package network
+
+import (
+	"testing"
+)
+
+func TestParseRoutingPolicyMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"rules", RoutingPolicyModeRules},
+		{"vrf", RoutingPolicyModeVRF},
+		{"fwmark", RoutingPolicyModeFwMark},
+		{"vfr", RoutingPolicyModeRules},
+		{"", RoutingPolicyModeRules},
+	}
+
+	for _, tt := range tests {
+		if got := ParseRoutingPolicyMode(tt.mode); got != tt.want {
+			t.Errorf("ParseRoutingPolicyMode('%s')='%s', want '%s'", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestVRFName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{2, "vrf-2"},
+		{17, "vrf-17"},
+		{2147483647, "vrf-2147483647"},
+	}
+
+	for _, tt := range tests {
+		got := vrfName(tt.index)
+		if got != tt.want {
+			t.Errorf("vrfName('%d')='%s', want '%s'", tt.index, got, tt.want)
+		}
+
+		if len(got) > 15 {
+			t.Errorf("vrfName('%d')='%s' exceeds IFNAMSIZ", tt.index, got)
+		}
+	}
+}
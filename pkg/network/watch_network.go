@@ -6,12 +6,13 @@ package network
 import (
 	"net"
 	"strconv"
-	"strings"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 
+	"github.com/vmware/network-event-broker/pkg/conf"
 	"github.com/vmware/network-event-broker/pkg/system"
 )
 
@@ -19,13 +20,19 @@ const (
 	MaxChannelSize = 1024
 )
 
-func WatchNetwork(n *Network) {
-	go n.watchAddresses()
+func WatchNetwork(n *Network, c *conf.Config) {
+	go n.watchAddresses(c)
 	go n.watchRoutes()
-	go n.watchLinks()
+	go n.watchLinks(c)
+
+	if c.Neighbor.Links != "" {
+		go n.watchNeighbors(c)
+	}
 }
 
-func (n *Network) watchAddresses() {
+func (n *Network) watchAddresses(c *conf.Config) {
+	rules := NewLinkMatcher(c.Network.RoutingPolicyRules)
+
 	updates := make(chan netlink.AddrUpdate)
 	done := make(chan struct{}, MaxChannelSize)
 
@@ -46,28 +53,41 @@ func (n *Network) watchAddresses() {
 				break
 			}
 
-			a := updates.LinkAddress.IP.String()
-			mask, _ := updates.LinkAddress.Mask.Size()
-
-			if strings.HasPrefix(a, "fe80") {
-				break
-			}
-
-			ip := a + "/" + strconv.Itoa(mask)
+			ip := updates.LinkAddress.String()
 
 			log.Infof("Received IP update: %v", updates)
 
 			if updates.NewAddr {
-				log.Infof("IP address='%s' added to link ifindex='%d'", ip, updates.LinkIndex)
-
-				n.oneAddressRuleAdd(ip, n.LinksByIndex[updates.LinkIndex], updates.LinkIndex)
+				log.Infof("IP address='%s' added or updated on link ifindex='%d' flags='%s'", ip, updates.LinkIndex, addressFlags(updates.Flags))
+
+				// Policy rules are installed once the address has left the tentative state
+				ready := n.updateAddressFlags(updates)
+				if ready && !updates.LinkAddress.IP.IsLinkLocalUnicast() && n.RoutingPolicyMode == RoutingPolicyModeRules {
+					n.Mutex.Lock()
+					link := n.LinksByIndex[updates.LinkIndex]
+					n.Mutex.Unlock()
+
+					if rules.Match(link) {
+						n.Mutex.Lock()
+						n.oneAddressRuleAdd(ip, link, updates.LinkIndex)
+						n.Mutex.Unlock()
+					}
+				}
 			} else {
 				log.Infof("IP address='%s' removed from link ifindex='%d'", ip, updates.LinkIndex)
 
-				log.Debugf("Dropping configuration link ifindex='%d' address='%s'", updates.LinkIndex, ip)
+				n.Mutex.Lock()
+				delete(n.AddressFlagsByKey, addressKey(updates.LinkIndex, ip))
+				n.Mutex.Unlock()
+
+				if !updates.LinkAddress.IP.IsLinkLocalUnicast() {
+					log.Debugf("Dropping configuration link ifindex='%d' address='%s'", updates.LinkIndex, ip)
 
-				n.dropConfiguration(updates.LinkIndex, ip)
+					n.dropConfiguration(updates.LinkIndex, ip)
+				}
 			}
+
+			n.UpdateMasquerade(updates.LinkIndex)
 		}
 	}
 }
@@ -90,13 +110,21 @@ func (n *Network) watchRoutes() {
 
 			log.Debugf("Received route update: %v", updates)
 
-			link, _ := net.InterfaceByIndex(updates.LinkIndex)
+			if n.MultiPath != nil && updates.Dst == nil && updates.Gw != nil {
+				ConfigureMultiPath(n)
+			}
+
+			link, err := net.InterfaceByIndex(updates.LinkIndex)
+			if err != nil {
+				break
+			}
+
 			system.ExecuteScripts(link.Name, updates.LinkIndex)
 		}
 	}
 }
 
-func (n *Network) watchLinks() {
+func (n *Network) watchLinks(c *conf.Config) {
 	updates := make(chan netlink.LinkUpdate)
 	done := make(chan struct{}, MaxChannelSize)
 
@@ -119,30 +147,106 @@ func (n *Network) watchLinks() {
 
 			log.Infof("Received Link update: %v", updates)
 
-			n.updateLink(updates)
+			n.updateLink(updates, c)
 		}
 	}
 }
 
-func (n *Network) updateLink(updates netlink.LinkUpdate) {
-	n.Mutex.Lock()
-	defer n.Mutex.Unlock()
+func (n *Network) updateLink(updates netlink.LinkUpdate, c *conf.Config) {
+	index := int(updates.Index)
+	name := updates.Attrs().Name
 
 	switch updates.Header.Type {
 	case syscall.RTM_DELLINK:
+		n.RevertLink(index, "link removed", true)
+
+		n.Mutex.Lock()
+
+		n.dropLinkConfiguration(index)
+
+		delete(n.RoutableLinks, index)
+		delete(n.GatewaysByIndex, index)
+		delete(n.LinksByIndex, index)
+		delete(n.LinkRenames, index)
+		delete(n.LinkPropertiesByIndex, index)
+		n.dropAddressFlags(index)
+		if n.LinksByName[name] == index {
+			delete(n.LinksByName, name)
+		}
+
+		n.Mutex.Unlock()
 
-		delete(n.LinksByIndex, int(updates.Index))
-		delete(n.LinksByName, updates.Attrs().Name)
+		n.removeTopology(updates.Link)
+		n.RestoreSysctls(index, name)
+		n.ForgetQdisc(index)
+		n.UpdateMasquerade(index)
 
-		log.Debugf("Link='%s' ifindex='%d' removed", updates.Attrs().Name, int(updates.Index))
+		log.Debugf("Link='%s' ifindex='%d' removed", name, index)
+
+		ConfigureMultiPath(n)
 
 	case syscall.RTM_NEWLINK:
+		n.Mutex.Lock()
+
+		old, known := n.LinksByIndex[index]
+		renamed := known && old != name
+		if renamed {
+			if n.LinksByName[old] == index {
+				delete(n.LinksByName, old)
+			}
 
-		n.LinksByIndex[int(updates.Index)] = updates.Attrs().Name
-		n.LinksByName[updates.Attrs().Name] = int(updates.Index)
+			n.LinkRenames[index] = append(n.LinkRenames[index], old)
+
+			if gs, ok := n.GatewaysByIndex[index]; ok {
+				gs.Link = name
+			}
+		}
+
+		n.LinksByIndex[index] = name
+		n.LinksByName[name] = index
+
+		n.Mutex.Unlock()
+
+		if renamed {
+			n.linkRenamed(index, old, name, c)
+		} else if !known {
+			log.Debugf("New link='%s' ifindex='%d' added", name, index)
+
+			n.ApplySysctls(index, name)
+		}
 
-		log.Debugf("New link='%s' ifindex='%d' added", updates.Attrs().Name, int(updates.Index))
+		if updates.Attrs().RawFlags&unix.IFF_LOWER_UP == 0 {
+			n.RevertLink(index, "no carrier", false)
+		}
+
+		n.updateTopology(updates.Link, !known)
+		n.updateLinkProperties(updates.Link, c)
+	}
+}
+
+func (n *Network) linkRenamed(index int, old string, name string, c *conf.Config) {
+	log.Infof("Link ifindex='%d' renamed from '%s' to '%s'", index, old, name)
+
+	system.ExecuteScriptsInDir(conf.LinkRenamedDir,
+		"OLD_LINK="+old,
+		"NEW_LINK="+name,
+		"LINK="+name,
+		"LINKINDEX="+strconv.Itoa(index),
+	)
+
+	n.ApplySysctls(index, name)
+	n.UpdateMasquerade(index)
+
+	// Configuration is keyed by link names, so apply what now matches the new name
+	if NewLinkMatcher(c.Network.RoutingPolicyRules).Match(name) {
+		ConfigureNetwork(name, n)
+	} else {
+		n.Mutex.Lock()
+		n.dropLinkConfiguration(index)
+		n.Mutex.Unlock()
 	}
+
+	ConfigureMultiPath(n)
 }
 
 func (n *Network) dropConfiguration(ifIndex int, address string) {
@@ -166,12 +270,21 @@ func (n *Network) dropConfiguration(ifIndex int, address string) {
 	rt, ok := n.RoutesByIndex[ifIndex]
 	if ok {
 
+		// In VRF and fwmark mode there are no per address rules. Keep the route while the link has addresses
+		if n.RoutingPolicyMode != RoutingPolicyModeRules {
+			if addresses, err := getIPv4AddressesByLink(n.LinksByIndex[ifIndex]); err == nil && len(addresses) > 0 {
+				return
+			}
+		}
+
 		if n.isRulesByTableEmpty(rt.Table) {
 
 			log.Debugf("Dropping GW='%s' link='%s' ifindex='%d'  Table='%d'", rt.Gw, n.LinksByIndex[ifIndex], ifIndex, rt.Table)
 
 			rt.RouteRemove()
 			delete(n.RoutesByIndex, ifIndex)
+
+			n.dropConnmark(ifIndex)
 		}
 	}
 }
@@ -9,6 +9,7 @@ import (
     "net"
     "os"
     "strings"
+    "time"
 
     "github.com/vmware/network-event-broker/pkg/conf"
 )
@@ -25,6 +26,28 @@ type Lease struct {
     Dns          []string
     DomainSearch []string
     Domain       []string
+    Ntp          []string
+    Expire       time.Time
+}
+
+// IsExpired reports whether the lease has expired. Leases without an expiry never do.
+func (l *Lease) IsExpired() bool {
+    return !l.Expire.IsZero() && time.Now().After(l.Expire)
+}
+
+// parseLeaseTime parses e.g. 'expire 4 2024/01/18 12:34:56;' written in UTC by dhclient.
+func parseLeaseTime(line string) time.Time {
+    f := strings.Fields(strings.TrimSuffix(line, ";"))
+    if len(f) != 4 {
+        return time.Time{}
+    }
+
+    t, err := time.Parse("2006/01/02 15:04:05", f[2]+" "+f[3])
+    if err != nil {
+        return time.Time{}
+    }
+
+    return t
 }
 
 func ParseIP(ip string) (net.IP, error) {
@@ -33,8 +56,7 @@ func ParseIP(ip string) (net.IP, error) {
     }
 
     a := net.ParseIP(ip)
-
-    if a.To4() == nil || a.To16() == nil {
+    if a == nil {
         return nil, errors.New("invalid")
     }
 
@@ -79,6 +101,8 @@ func ParseDHClientLease() (map[string]*Lease, error) {
         }
 
         switch {
+        case strings.HasPrefix(line, "expire "):
+            lease.Expire = parseLeaseTime(line)
         case strings.Contains(line, "interface"):
             lease.Interface = strings.Split(line, "\"")[1]
         case strings.Contains(line, "fixed-address"):
@@ -89,6 +113,8 @@ func ParseDHClientLease() (map[string]*Lease, error) {
             lease.Routers = strings.TrimSuffix(strings.Split(line, " ")[2], ";")
         case strings.Contains(line, "dhcp-server-identifier"):
             lease.Server = strings.TrimSuffix(strings.Split(line, " ")[2], ";")
+        case strings.Contains(line, "ntp-servers"):
+            lease.Ntp = strings.Split(strings.TrimSuffix(strings.Split(line, " ")[2], ";"), ",")
         case strings.Contains(line, "domain-name-servers"):
             lease.Dns = strings.Split(strings.TrimSuffix(strings.Split(line, " ")[2], ";"), ",")
         case strings.Contains(line, "domain-name"):
@@ -107,7 +133,14 @@ func ParseDHClientLease() (map[string]*Lease, error) {
         case strings.Contains(line, "domain-search"):
             s := strings.TrimSuffix(strings.ReplaceAll(line, "option domain-search", ""), ";")
             s = strings.ReplaceAll(s, ",", "")
-            lease.DomainSearch = strings.Split(s, "\"")
+            t := strings.Split(s, "\"")
+
+            for _, d := range t {
+                if strings.TrimSpace(d) == "" {
+                    continue
+                }
+                lease.DomainSearch = append(lease.DomainSearch, d)
+            }
         }
     }
 
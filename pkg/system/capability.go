@@ -4,31 +4,70 @@
 package system
 
 import (
+	"os"
+	"os/exec"
 	"syscall"
 
 	"github.com/syndtr/gocapability/capability"
 	"golang.org/x/sys/unix"
 )
 
-func ApplyCapability(c *syscall.Credential) error {
+// ApplyCapability limits the capabilities to the ones network-broker needs and the given extra
+// ones.
+func ApplyCapability(c *syscall.Credential, extra ...capability.Cap) error {
 	caps, err := capability.NewPid2(0)
 	if err != nil {
 		return err
 	}
 
 	allCapabilityTypes := capability.CAPS | capability.BOUNDS | capability.AMBS
+	keep := append([]capability.Cap{capability.CAP_NET_ADMIN, capability.CAP_NET_RAW, capability.CAP_SYS_ADMIN}, extra...)
 
 	caps.Clear(capability.CAPS | capability.BOUNDS | capability.AMBS)
-	caps.Set(capability.BOUNDS, capability.CAP_NET_ADMIN, capability.CAP_SYS_ADMIN)
-	caps.Set(capability.PERMITTED, capability.CAP_NET_ADMIN, capability.CAP_SYS_ADMIN)
-	caps.Set(capability.INHERITABLE, capability.CAP_NET_ADMIN, capability.CAP_SYS_ADMIN)
-	caps.Set(capability.EFFECTIVE, capability.CAP_NET_ADMIN, capability.CAP_SYS_ADMIN)
-
-	caps.Clear(capability.AMBIENT)
+	caps.Set(capability.BOUNDS, keep...)
+	caps.Set(capability.PERMITTED, keep...)
+	caps.Set(capability.INHERITABLE, keep...)
+	caps.Set(capability.EFFECTIVE, keep...)
 
 	return caps.Apply(allCapabilityTypes)
 }
 
+// CapabilityCommand returns the command of a helper which is handed those of the capabilities
+// network-broker holds. Scripts are run without any.
+func CapabilityCommand(keep []capability.Cap, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+
+	// root keeps its capabilities across exec anyway
+	if os.Geteuid() == 0 {
+		return cmd
+	}
+
+	// Raising an ambient capability which is not permitted fails the exec
+	caps, err := capability.NewPid2(0)
+	if err != nil || caps.Load() != nil {
+		return cmd
+	}
+
+	var ambient []uintptr
+	for _, c := range keep {
+		if caps.Get(capability.PERMITTED, c) && caps.Get(capability.INHERITABLE, c) {
+			ambient = append(ambient, uintptr(c))
+		}
+	}
+
+	if len(ambient) > 0 {
+		cmd.SysProcAttr = &syscall.SysProcAttr{AmbientCaps: ambient}
+	}
+
+	return cmd
+}
+
+// NetAdminCommand returns the command of a helper such as nft(8) or tc(8), which is handed
+// CAP_NET_ADMIN.
+func NetAdminCommand(name string, args ...string) *exec.Cmd {
+	return CapabilityCommand([]capability.Cap{capability.CAP_NET_ADMIN}, name, args...)
+}
+
 func EnableKeepCapability() error {
 	if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0); err != nil {
 		return err
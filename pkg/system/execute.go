@@ -7,14 +7,63 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"runtime"
 	"strconv"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netns"
 
 	"github.com/vmware/network-event-broker/pkg/conf"
 )
 
+// EventHook is called for each event with its script dir and the environment of its scripts.
+type EventHook func(dir string, env []string)
+
+type eventHook struct {
+	h EventHook
+}
+
+var (
+	eventHooksMutex sync.Mutex
+	eventHooks      []*eventHook
+)
+
+// AddEventHook registers a hook and returns the function removing it again.
+func AddEventHook(h EventHook) func() {
+	eventHooksMutex.Lock()
+	defer eventHooksMutex.Unlock()
+
+	hook := &eventHook{h: h}
+	eventHooks = append(eventHooks, hook)
+
+	return func() {
+		eventHooksMutex.Lock()
+		defer eventHooksMutex.Unlock()
+
+		for i, e := range eventHooks {
+			if e == hook {
+				eventHooks = append(eventHooks[:i:i], eventHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// RunEventHooks calls the hooks of an event whether or not it has scripts.
+func RunEventHooks(dir string, env ...string) {
+	eventHooksMutex.Lock()
+	hooks := eventHooks
+	eventHooksMutex.Unlock()
+
+	for _, e := range hooks {
+		e.h(dir, env)
+	}
+}
+
 func ExecuteScripts(link string, index int) error {
+	RunEventHooks(conf.RoutesModifiedDir, "LINK="+link, "LINKINDEX="+strconv.Itoa(index))
+
 	scripts, err := ReadAllScriptInConfDir(path.Join(conf.ConfPath, conf.RoutesModifiedDir))
 	if err != nil {
 		log.Errorf("Failed to read script dir '%s'", path.Join(conf.ConfPath, conf.RoutesModifiedDir))
@@ -52,3 +101,81 @@ func ExecuteScripts(link string, index int) error {
 
 	return nil
 }
+
+func ExecuteScriptsInDir(dir string, env ...string) error {
+	RunEventHooks(dir, env...)
+
+	return executeScriptsInDir(dir, env...)
+}
+
+// executeScriptsInDir executes the scripts without calling the hooks of the event.
+func executeScriptsInDir(dir string, env ...string) error {
+	scriptDir := path.Join(conf.ConfPath, dir)
+
+	scripts, err := ReadAllScriptInConfDir(scriptDir)
+	if err != nil {
+		log.Errorf("Failed to read script dir '%s'", scriptDir)
+		return err
+	}
+
+	if len(scripts) <= 0 {
+		log.Debugf("No script in '%+v'", dir)
+		return nil
+	}
+
+	for _, s := range scripts {
+		script := path.Join(scriptDir, s)
+
+		log.Debugf("Executing script '%s' in dir='%v' env='%v'", script, dir, env)
+
+		cmd := exec.Command(script)
+		cmd.Env = append(os.Environ(), env...)
+
+		if err := cmd.Run(); err != nil {
+			log.Errorf("Failed to execute script='%s': %v", script, err)
+			continue
+		}
+
+		log.Debugf("Successfully executed script '%s' in dir='%v'", script, dir)
+	}
+
+	return nil
+}
+
+// ExecuteScriptsInNamespace executes the scripts in dir inside the network namespace at nsPath.
+func ExecuteScriptsInNamespace(dir string, nsPath string, env ...string) error {
+	RunEventHooks(dir, env...)
+
+	runtime.LockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer origin.Close()
+
+	target, err := netns.GetFromPath(nsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer target.Close()
+
+	if err := netns.Set(target); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+
+	// Children forked from this thread inherit its network namespace
+	err = executeScriptsInDir(dir, env...)
+
+	// Leave the thread locked when it can not be switched back, so that the runtime drops it
+	if err := netns.Set(origin); err != nil {
+		log.Errorf("Failed to switch back to the original network namespace: %v", err)
+		return err
+	}
+	runtime.UnlockOSThread()
+
+	return err
+}
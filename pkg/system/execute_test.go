@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 VMware, Inc.
+
+package system
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEventHooksRunOnce(t *testing.T) {
+	type event struct {
+		dir string
+		env []string
+	}
+
+	var events []event
+	remove := AddEventHook(func(dir string, env []string) {
+		events = append(events, event{dir, env})
+	})
+	t.Cleanup(remove)
+
+	// The script dirs do not exist, the hooks run anyway
+	ExecuteScriptsInDir("test.d", "LINK=eth0")
+
+	// Switching network namespaces needs CAP_SYS_ADMIN
+	if os.Geteuid() == 0 {
+		ExecuteScriptsInNamespace("test-netns.d", "/proc/self/ns/net", "LINK=eth1")
+	}
+
+	want := []event{{"test.d", []string{"LINK=eth0"}}}
+	if os.Geteuid() == 0 {
+		want = append(want, event{"test-netns.d", []string{"LINK=eth1"}})
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("Hooks called with '%+v', want '%+v'", events, want)
+	}
+}
+
+func TestRemoveEventHook(t *testing.T) {
+	var calls []string
+
+	removeA := AddEventHook(func(dir string, env []string) { calls = append(calls, "a") })
+	removeB := AddEventHook(func(dir string, env []string) { calls = append(calls, "b") })
+	t.Cleanup(removeB)
+
+	RunEventHooks("test.d")
+	removeA()
+	RunEventHooks("test.d")
+
+	// Removing twice is harmless
+	removeA()
+
+	if want := []string{"a", "b", "b"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("Hooks called='%v', want '%v'", calls, want)
+	}
+}
@@ -4,6 +4,7 @@
 package system
 
 import (
+	"os"
 	"os/user"
 	"strconv"
 	"syscall"
@@ -66,3 +67,12 @@ func GetGroupCredentials(grp string) (*user.Group, error) {
 
 	return group, nil
 }
+
+// CreateUserDir creates dir owned by the user, e.g. before switching to it.
+func CreateUserDir(dir string, c *syscall.Credential) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.Chown(dir, int(c.Uid), int(c.Gid))
+}